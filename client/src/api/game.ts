@@ -14,6 +14,17 @@ const ERROR: string = "error";
 const WRONG_SUBMISSION: string = "wrong_submission";
 const PUZZLE_ASSIGN: string = "puzzle_assign";
 const CORRECT_SUBMISSION: string = "correct_submission";
+const HINT_REQUEST: string = "hint_request";
+const HINT: string = "hint";
+const CONNECTION_LOST: string = "connection_lost";
+const RESUMED: string = "resumed";
+const OPPONENT_RESUMED: string = "opponent_resumed";
+const FORFEIT: string = "forfeit";
+const TIME_UP: string = "time_up";
+const OPPONENT_JOINED: string = "opponent_joined";
+const READY: string = "ready";
+const COUNTDOWN: string = "countdown";
+const LOBBY_TIMEOUT: string = "lobby_timeout";
 
 const messageType = {
 	JOIN,
@@ -29,6 +40,84 @@ const messageType = {
 	WRONG_SUBMISSION,
 	PUZZLE_ASSIGN,
 	CORRECT_SUBMISSION,
+	HINT_REQUEST,
+	HINT,
+	CONNECTION_LOST,
+	RESUMED,
+	OPPONENT_RESUMED,
+	FORFEIT,
+	TIME_UP,
+	OPPONENT_JOINED,
+	READY,
+	COUNTDOWN,
+	LOBBY_TIMEOUT,
+};
+
+// Clocks further apart than this are corrected with the server's time.
+// Closer ones are trusted as they are, so the latency of the countdown
+// message does not delay the reveal for the player further from the server.
+const CLOCK_SKEW_TOLERANCE_MS = 1000;
+
+// Where a submission is malformed, as reported with a wrong_submission
+type ParseError = {
+	pos: number;
+	kind: string;
+	near?: string;
+};
+
+// How a wrong submission evaluates, one reduction at a time
+type Explanation = {
+	steps: { expression: string; value: string; pos: number; end: number }[];
+	value: string;
+};
+
+// Sent on joining; the token takes the seat back after a dropped connection
+type Session = {
+	resumeToken: string;
+	graceMs: number;
+};
+
+// Sent with puzzle_assign and resumed; a zero limit means no clock
+type Clock = {
+	timeLimitMs: number;
+};
+
+// What a puzzle's answers may use, as the room's spec sets it
+type Rules = {
+	concatenation: boolean;
+	unaryMinus: boolean;
+	powers: boolean;
+	decimals: boolean;
+	factorial: boolean;
+	squareRoot: boolean;
+};
+
+type Spec = {
+	digits: number;
+	target: number;
+	alphabet: string;
+	rules: Rules;
+};
+
+type Puzzle = {
+	problem: string;
+	spec: Spec;
+};
+
+// Sent on resuming: the game as it stands and what was missed
+type Resume = Session & Clock & {
+	puzzle?: Puzzle;
+	elapsedMs: number;
+	missed: Message[];
+};
+
+// Sent once both players are ready, with the puzzle to reveal at startsAt.
+// Times are Unix milliseconds; serverTime corrects for the local clock.
+type Countdown = {
+	serverTime: number;
+	startsAt: number;
+	durationMs: number;
+	puzzle: Puzzle;
 };
 
 type Message = {
@@ -36,14 +125,24 @@ type Message = {
 	content: string;
 	roomId: string;
 	userId: string;
+	senderId?: string;
+	details?: ParseError | Explanation | Session | Resume | Clock | Countdown;
 };
 
+// Resume tokens live in session storage, so a reload of the page resumes
+// the game instead of leaving it
+function resumeTokenKey(roomId: string): string {
+	return `hectoc-resume-${roomId}`;
+}
+
 // This function creates a new WebSocket connection to the game server
 function create(roomId: string, userId: string): WebSocket {
+	const resumeToken = sessionStorage.getItem(resumeTokenKey(roomId)) ?? "";
+
 	const socket = new WebSocket(
 		`ws://${import.meta.env.VITE_GAME_SERVER_HOST}:${
 			import.meta.env.VITE_GAME_SERVER_PORT
-		}/api/v1/ws/rooms/${roomId}/join?userId=${userId}`
+		}/api/v1/ws/rooms/${roomId}/join?userId=${userId}&resumeToken=${resumeToken}`
 	);
 
 	return socket;
@@ -53,18 +152,29 @@ class WebSocketClient {
 	socket: WebSocket | null;
 	roomId: string;
 	userId: string;
+	// Called with the puzzle when the countdown ends
+	onReveal: ((puzzle: Puzzle) => void) | null;
+	revealTimer: number | undefined;
+	// The spec of the room's puzzle, once the server has sent it, so
+	// answers are checked under the room's rules
+	spec: Spec | null;
 
 	constructor(roomId: string, userId: string) {
 		this.socket = null;
 		this.roomId = roomId;
 		this.userId = userId;
+		this.onReveal = null;
+		this.revealTimer = undefined;
+		this.spec = null;
 	}
 
 	initiate() {
 		this.socket = create(this.roomId, this.userId);
 
+		// Closing without a leave message holds the seat, in case the page
+		// is only reloading
 		window.addEventListener("beforeunload", () => {
-			this.uninitiate();
+			this.close();
 		});
 
 		this.socket.addEventListener("open", (e) => {
@@ -93,6 +203,10 @@ class WebSocketClient {
 		this.socket.close();
 		this.socket = null;
 
+		this.cancelReveal();
+
+		sessionStorage.removeItem(resumeTokenKey(this.roomId));
+
 		console.log("WebSocketClient: uninitiate");
 	}
 
@@ -115,6 +229,41 @@ class WebSocketClient {
 		}
 	}
 
+	sendReady() {
+		if (this.socket?.readyState === WebSocket.OPEN) {
+			const message: Message = {
+				type: messageType.READY,
+				content: "",
+				roomId: this.roomId,
+				userId: this.userId,
+			};
+
+			this.socket.send(JSON.stringify(message));
+
+			console.log("WebSocketClient: sendReady", message);
+		}
+	}
+
+	// The puzzle arrives with the countdown and stays hidden until startsAt,
+	// so both players see it at the same instant
+	scheduleReveal(countdown: Countdown) {
+		this.cancelReveal();
+		this.spec = countdown.puzzle.spec;
+
+		const offset = countdown.serverTime - Date.now();
+		const skew = Math.abs(offset) > CLOCK_SKEW_TOLERANCE_MS ? offset : 0;
+		const delay = countdown.startsAt - skew - Date.now();
+
+		this.revealTimer = window.setTimeout(() => {
+			this.onReveal?.(countdown.puzzle);
+		}, Math.max(delay, 0));
+	}
+
+	cancelReveal() {
+		window.clearTimeout(this.revealTimer);
+		this.revealTimer = undefined;
+	}
+
 	startReceivingMessages() {
 		if (!this.socket) {
 			return;
@@ -123,16 +272,47 @@ class WebSocketClient {
 		this.socket.addEventListener("message", (event) => {
 			const message: Message = JSON.parse(event.data);
 
+			switch (message.type) {
+				case messageType.ROOM_CREATED:
+				case messageType.JOIN_SUCCESS:
+				case messageType.RESUMED:
+					sessionStorage.setItem(
+						resumeTokenKey(this.roomId),
+						(message.details as Session).resumeToken
+					);
+					break;
+			}
+
 			switch (message.type) {
 				case messageType.JOIN_SUCCESS:
 					toast.success("You have joined the room");
 					break;
+				case messageType.RESUMED: {
+					const puzzle = (message.details as Resume).puzzle;
+
+					if (puzzle) {
+						this.spec = puzzle.spec;
+						this.onReveal?.(puzzle);
+					}
+
+					toast.success("You are back in the game");
+					break;
+				}
+				case messageType.OPPONENT_RESUMED:
+					toast.success("Your opponent is back");
+					break;
 				case messageType.LEAVE_SUCCESS:
 					toast.success("You have left the room");
 					break;
 				case messageType.OPPONENT_LEFT:
+					this.cancelReveal();
 					toast.error("Your opponent has left the room");
 					break;
+				case messageType.CONNECTION_LOST:
+					// A countdown is called off when a player drops out
+					this.cancelReveal();
+					toast.error("Your opponent lost their connection");
+					break;
 				case messageType.ROOM_CREATED:
 					toast.success("Room created successfully");
 					break;
@@ -142,6 +322,28 @@ class WebSocketClient {
 				case messageType.END:
 					toast.success(message.content);
 					break;
+				case messageType.FORFEIT:
+					toast.success(message.content);
+					break;
+				case messageType.TIME_UP:
+					toast(message.content);
+					break;
+				case messageType.OPPONENT_JOINED:
+					toast.success("Your opponent has joined. Get ready!");
+					break;
+				case messageType.READY:
+					if (message.senderId !== this.userId) {
+						toast("Your opponent is ready");
+					}
+					break;
+				case messageType.COUNTDOWN:
+					this.scheduleReveal(message.details as Countdown);
+					toast(message.content);
+					break;
+				case messageType.LOBBY_TIMEOUT:
+					this.cancelReveal();
+					toast.error(message.content);
+					break;
 				case messageType.ERROR:
 					toast.error(message.content);
 					break;
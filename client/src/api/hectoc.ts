@@ -0,0 +1,73 @@
+// The game server's puzzle rules, compiled to WebAssembly from
+// game-server/cmd/hectoc-wasm, so submissions are checked exactly as the
+// server checks them. npm run wasm builds hectoc.wasm and copies Go's
+// wasm_exec.js into public/; dev and build run it first.
+
+type HectocError = {
+	message: string;
+	details?: { pos: number; end?: number; kind: string; near?: string };
+};
+
+type HectocResult<T> =
+	| { ok: true; value: T }
+	| { ok: false; error: HectocError };
+
+type Explanation = {
+	steps: { expression: string; value: string; pos: number; end: number }[];
+	value: string;
+};
+
+type HectocApi = {
+	verify(problem: string, expression: string, spec?: object): HectocResult<boolean>;
+	parse(expression: string): HectocResult<unknown>;
+	explain(expression: string): HectocResult<Explanation>;
+	validateDigits(problem: string, expression: string): HectocResult<boolean>;
+};
+
+type GoRuntime = {
+	importObject: WebAssembly.Imports;
+	run(instance: WebAssembly.Instance): Promise<void>;
+};
+
+declare global {
+	interface Window {
+		Go?: new () => GoRuntime;
+		hectoc?: HectocApi;
+	}
+}
+
+let loading: Promise<HectocApi> | null = null;
+
+function loadScript(src: string): Promise<void> {
+	return new Promise((resolve, reject) => {
+		const script = document.createElement("script");
+		script.src = src;
+		script.onload = () => resolve();
+		script.onerror = () => reject(new Error(`failed to load ${src}`));
+		document.head.appendChild(script);
+	});
+}
+
+// This function loads the rules once and resolves to the hectoc API
+function load(): Promise<HectocApi> {
+	if (!loading) {
+		loading = (async () => {
+			await loadScript("/wasm_exec.js");
+
+			const go = new window.Go!();
+			const { instance } = await WebAssembly.instantiateStreaming(
+				fetch("/hectoc.wasm"),
+				go.importObject
+			);
+
+			go.run(instance);
+
+			return window.hectoc!;
+		})();
+	}
+
+	return loading;
+}
+
+export { load };
+export type { HectocApi, HectocResult, HectocError };
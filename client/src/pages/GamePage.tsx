@@ -1,9 +1,10 @@
 import { useState, useEffect } from "react";
 import WebSocketClient from "../api/game";
+import { load as loadHectoc } from "../api/hectoc";
 import { useParams } from "react-router-dom";
 import { useSelector } from "react-redux";
 import { RootState } from "../redux/store";
-import { Toaster } from "sonner";
+import { Toaster, toast } from "sonner";
 
 const MathGame = () => {
 	const { roomId } = useParams<{ roomId: string }>();
@@ -34,6 +35,15 @@ const MathGame = () => {
 			console.log("WebSocketClient initialized:", client);
 		}
 
+		// Play the room's puzzle once it is revealed
+		if (client) {
+			client.onReveal = (puzzle) => {
+				setSequence(puzzle.problem.split(""));
+				setExpression([]);
+				setCurrentDigitIndex(0);
+			};
+		}
+
 		// Set error handler
 		// client.onError = (error: Error) => {
 		// 	console.error("WebSocket error occurred:", error);
@@ -54,6 +64,13 @@ const MathGame = () => {
 		};
 	}, []);
 
+	// Fetch the puzzle rules ahead of the first submission
+	useEffect(() => {
+		loadHectoc().catch((error) => {
+			console.error("Failed to load the puzzle rules", error);
+		});
+	}, []);
+
 	// Timer effect
 	useEffect(() => {
 		if (timer > 0 && !gameOver && start) {
@@ -128,25 +145,33 @@ const MathGame = () => {
 		return expression.join("");
 	};
 
-	// Evaluate the expression
-	const evaluateExpression = () => {
+	// Check the expression with the server's own rules, under the room's
+	// spec once the server has sent it
+	const evaluateExpression = async () => {
 		try {
-			const expressionStr = getExpressionString();
-
-			// Replace ^ with ** for JavaScript's exponentiation
-			const jsExpression = expressionStr.replace(/\^/g, "**");
+			const hectoc = await loadHectoc();
+			const result = hectoc.verify(
+				sequence.join(""),
+				getExpressionString(),
+				wsClient?.spec ?? undefined
+			);
+
+			if (!result.ok) {
+				toast.error(result.error.message);
+				return false;
+			}
 
-			// Evaluate and check if equals 100
-			const result = eval(jsExpression);
-			return result === 100;
+			return result.value;
 		} catch (error) {
+			console.error("Failed to load the puzzle rules", error);
+			toast.error("Could not check your answer");
 			return false;
 		}
 	};
 
 	// Handle submission
-	const handleSubmit = () => {
-		const result = evaluateExpression();
+	const handleSubmit = async () => {
+		const result = await evaluateExpression();
 		setSuccess(result);
 		setGameOver(true);
 	};
@@ -41,6 +41,8 @@ type application struct {
 	cacheStorage 	cache.Storage
 	hub 			*ws.Hub
 	store 			store.Storage
+	daily 			dailyCache
+	solver 			*solveLimiter
 }
 
 func (app *application) mount() http.Handler {
@@ -66,6 +68,23 @@ func (app *application) mount() http.Handler {
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Get("/health", app.healthCheckHandler)
 
+		r.Get("/metrics/puzzle-pool", app.puzzlePoolMetricsHandler)
+		r.Get("/metrics/hub", app.hubMetricsHandler)
+
+		r.Route("/puzzles/daily", func(r chi.Router) {
+			r.Get("/", app.getDailyPuzzleHandler)
+			r.Post("/", app.submitDailyPuzzleHandler)
+			r.Get("/leaderboard", app.dailyLeaderboardHandler)
+		})
+
+		r.Get("/puzzles/{problem}/solutions", app.puzzleSolutionsHandler)
+
+		r.Route("/hectoc", func(r chi.Router) {
+			r.Post("/verify", app.verifyHandler)
+			r.Post("/solve", app.solveHandler)
+			r.Get("/random", app.randomPuzzleHandler)
+		})
+
 		r.Get("/ws/rooms/{roomId}/join", app.joinRoomHandler)
 	})
 
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/eclairjit/hecto-clash-hf/game-server/internal/store"
+	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
+)
+
+const (
+	DAILY_LEADERBOARD_LIMIT     = 50
+	DAILY_LEADERBOARD_MAX_LIMIT = 100
+)
+
+// dailyCache keeps the current daily puzzle solved in memory
+type dailyCache struct {
+	mu     sync.Mutex
+	date   string
+	puzzle *hectoc.Hectoc
+}
+
+type dailyPuzzleResponse struct {
+	Date       string              `json:"date"`
+	Problem    string              `json:"problem"`
+	Spec       hectoc.Spec         `json:"spec"`
+	Difficulty hectoc.Difficulty   `json:"difficulty"`
+	Attempt    *store.DailyAttempt `json:"attempt,omitempty"`
+}
+
+type dailySubmissionPayload struct {
+	UserID     int64  `json:"userId" validate:"required,gt=0"`
+	Expression string `json:"expression" validate:"required,max=256"`
+}
+
+type dailySubmissionResponse struct {
+	Correct bool                `json:"correct"`
+	Message string              `json:"message"`
+	Attempt *store.DailyAttempt `json:"attempt,omitempty"`
+}
+
+// dailyPuzzle returns the puzzle for the UTC date of now. The first server
+// to need a date's puzzle stores it, so every server serves the same one.
+func (app *application) dailyPuzzle(ctx context.Context, now time.Time) (string, *hectoc.Hectoc, error) {
+	date := now.UTC().Format(time.DateOnly)
+
+	app.daily.mu.Lock()
+	defer app.daily.mu.Unlock()
+
+	if app.daily.date == date {
+		return date, app.daily.puzzle, nil
+	}
+
+	puzzle, err := hectoc.Daily(now)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	record := &store.DailyPuzzle{
+		Date:    date,
+		Problem: puzzle.Problem,
+		Target:  puzzle.Spec.Target,
+	}
+
+	if err := app.store.Daily.GetOrCreatePuzzle(ctx, record); err != nil {
+		return "", nil, err
+	}
+
+	if record.Problem != puzzle.Problem || record.Target != puzzle.Spec.Target {
+		spec := hectoc.DefaultSpec
+		spec.Target = record.Target
+
+		if puzzle, err = spec.Puzzle(record.Problem); err != nil {
+			return "", nil, err
+		}
+	}
+
+	app.daily.date = date
+	app.daily.puzzle = puzzle
+
+	return date, puzzle, nil
+}
+
+// getDailyPuzzleHandler serves today's puzzle. Passing a userId starts that
+// player's clock.
+func (app *application) getDailyPuzzleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	date, puzzle, err := app.dailyPuzzle(ctx, time.Now())
+
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to load the daily puzzle")
+		return
+	}
+
+	response := &dailyPuzzleResponse{
+		Date:       date,
+		Problem:    puzzle.Problem,
+		Spec:       puzzle.Spec,
+		Difficulty: puzzle.Difficulty,
+	}
+
+	if userID := r.URL.Query().Get("userId"); userID != "" {
+		id, err := strconv.ParseInt(userID, 10, 64)
+
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid user ID")
+			return
+		}
+
+		attempt := &store.DailyAttempt{
+			Date:   date,
+			UserID: id,
+		}
+
+		if err := app.store.Daily.StartAttempt(ctx, attempt); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "failed to start the daily attempt")
+			return
+		}
+
+		response.Attempt = attempt
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// submitDailyPuzzleHandler checks a solution to today's puzzle and, if it is
+// correct, stops the player's clock
+func (app *application) submitDailyPuzzleHandler(w http.ResponseWriter, r *http.Request) {
+	var payload dailySubmissionPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+
+	date, puzzle, err := app.dailyPuzzle(ctx, time.Now())
+
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to load the daily puzzle")
+		return
+	}
+
+	attempt := &store.DailyAttempt{
+		Date:   date,
+		UserID: payload.UserID,
+	}
+
+	if err := app.store.Daily.GetAttempt(ctx, attempt); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			writeJSONError(w, http.StatusConflict, "fetch today's puzzle before submitting")
+		default:
+			writeJSONError(w, http.StatusInternalServerError, "failed to load the daily attempt")
+		}
+		return
+	}
+
+	if attempt.SolvedAt != "" {
+		writeJSONError(w, http.StatusConflict, "today's puzzle is already solved")
+		return
+	}
+
+	verified, err := puzzle.Verify(payload.Expression)
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !verified {
+		response := &dailySubmissionResponse{
+			Correct: false,
+			Message: "Incorrect submission. Try again.",
+		}
+
+		if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	attempt.Solution = payload.Expression
+
+	if err := app.store.Daily.SolveAttempt(ctx, attempt); err != nil {
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			writeJSONError(w, http.StatusConflict, "today's puzzle is already solved")
+		default:
+			writeJSONError(w, http.StatusInternalServerError, "failed to record the solution")
+		}
+		return
+	}
+
+	response := &dailySubmissionResponse{
+		Correct: true,
+		Message: "Congratulations! You have solved today's puzzle.",
+		Attempt: attempt,
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// dailyLeaderboardHandler lists the fastest solvers of a day's puzzle,
+// today's unless a date is given
+func (app *application) dailyLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	date := time.Now().UTC().Format(time.DateOnly)
+
+	if d := query.Get("date"); d != "" {
+		if _, err := time.Parse(time.DateOnly, d); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "date must be formatted as YYYY-MM-DD")
+			return
+		}
+
+		date = d
+	}
+
+	limit := DAILY_LEADERBOARD_LIMIT
+
+	if l := query.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+
+		if err != nil || n < 1 || n > DAILY_LEADERBOARD_MAX_LIMIT {
+			writeJSONError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+
+		limit = n
+	}
+
+	results, err := app.store.Daily.Leaderboard(r.Context(), date, limit)
+
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "failed to load the leaderboard")
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, results); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/eclairjit/hecto-clash-hf/game-server/internal/store"
 	"github.com/eclairjit/hecto-clash-hf/game-server/internal/ws"
+	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
 	"github.com/go-chi/chi/v5"
 	"golang.org/x/net/context"
 )
@@ -25,6 +28,13 @@ func (app *application) joinRoomHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	settings, err := readRoomSettings(r)
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	ctx := context.Background()
 
 	// Get the gameID from the cache
@@ -65,15 +75,132 @@ func (app *application) joinRoomHandler(w http.ResponseWriter, r *http.Request)
 
 	cl := &ws.Client{
 		Conn:     conn,
-        Message:  make(chan *ws.Message, 10),
+        Message:  make(chan *ws.Message, ws.CLIENT_QUEUE_SIZE),
         ID:       clientID,
         RoomID:   roomID,
+		Settings: settings,
+		ResumeToken: r.URL.Query().Get("resumeToken"),
 	}
 
 	// Register the client with the hub
 	app.hub.Register <- cl
 
 	// Start handling WebSocket messages
-	go cl.WriteMessage()
+	go cl.WriteMessage(app.hub)
 	cl.ReadMessage(app.hub)
+}
+
+// readRoomSettings builds the room settings requested in the query string,
+// starting from the defaults
+func readRoomSettings(r *http.Request) (ws.RoomSettings, error) {
+	settings := ws.DefaultRoomSettings
+	query := r.URL.Query()
+
+	if digits := query.Get("digits"); digits != "" {
+		n, err := strconv.Atoi(digits)
+
+		if err != nil {
+			return settings, errors.New("invalid digits")
+		}
+
+		settings.Spec.Digits = n
+	}
+
+	if target := query.Get("target"); target != "" {
+		n, err := strconv.ParseInt(target, 10, 64)
+
+		if err != nil {
+			return settings, errors.New("invalid target")
+		}
+
+		settings.Spec.Target = n
+	}
+
+	if alphabet := query.Get("alphabet"); alphabet != "" {
+		settings.Spec.Alphabet = alphabet
+	}
+
+	if name := query.Get("rules"); name != "" {
+		rules, ok := hectoc.RuleSets[name]
+
+		if !ok {
+			return settings, errors.New("unknown rules")
+		}
+
+		settings.Spec.Rules = rules
+	}
+
+	// Single rules can be switched on or off on top of the rule set, e.g.
+	// to opt into factorials and square roots
+	toggles := []struct {
+		name string
+		rule *bool
+	}{
+		{"concatenation", &settings.Spec.Rules.Concatenation},
+		{"unaryMinus", &settings.Spec.Rules.UnaryMinus},
+		{"powers", &settings.Spec.Rules.Powers},
+		{"decimals", &settings.Spec.Rules.Decimals},
+		{"factorial", &settings.Spec.Rules.Factorial},
+		{"squareRoot", &settings.Spec.Rules.SquareRoot},
+	}
+
+	for _, toggle := range toggles {
+		if value := query.Get(toggle.name); value != "" {
+			allowed, err := strconv.ParseBool(value)
+
+			if err != nil {
+				return settings, fmt.Errorf("invalid %s", toggle.name)
+			}
+
+			*toggle.rule = allowed
+		}
+	}
+
+	// Difficulty is one of the named bands, so rooms share a few pooled
+	// streams of puzzles instead of asking for one each
+	if difficulty := query.Get("difficulty"); difficulty != "" {
+		band, ok := hectoc.DifficultyBands[difficulty]
+
+		if !ok {
+			return settings, errors.New("difficulty must be easy, medium or hard")
+		}
+
+		settings.MinDifficulty, settings.MaxDifficulty = band[0], band[1]
+	}
+
+	if practice := query.Get("practice"); practice != "" {
+		allowed, err := strconv.ParseBool(practice)
+
+		if err != nil {
+			return settings, errors.New("invalid practice")
+		}
+
+		settings.Practice = allowed
+	}
+
+	if hintPenalty := query.Get("hintPenalty"); hintPenalty != "" {
+		n, err := strconv.Atoi(hintPenalty)
+
+		if err != nil || n < 0 {
+			return settings, errors.New("invalid hintPenalty")
+		}
+
+		settings.HintPenalty = n
+	}
+
+	if timeLimit := query.Get("timeLimit"); timeLimit != "" {
+		n, err := strconv.Atoi(timeLimit)
+
+		if err != nil || n < 0 {
+			return settings, errors.New("invalid timeLimit")
+		}
+
+		settings.TimeLimit = n
+	}
+
+	if err := settings.Spec.Validate(); err != nil {
+		return settings, err
+	}
+
+	return settings, nil
 }
\ No newline at end of file
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
+)
+
+const (
+	// MAX_SOLVE_DIGITS bounds the puzzles solved on demand; the solver's
+	// work grows steeply with every digit past it
+	MAX_SOLVE_DIGITS = 6
+	// DEFAULT_SOLVE_CONCURRENCY is how many puzzles are solved at once
+	DEFAULT_SOLVE_CONCURRENCY = 2
+	// DEFAULT_SOLVE_TIMEOUT is how long a request waits for its solution,
+	// including the wait for a free slot
+	DEFAULT_SOLVE_TIMEOUT = 5 * time.Second
+)
+
+var ErrSolverBusy = errors.New("the solver is busy, try again later")
+
+// solveLimiter bounds the work the solve endpoint can be made to do: only
+// so many puzzles are solved at once, and a request gives up on its
+// solution after the timeout
+type solveLimiter struct {
+	slots   chan struct{}
+	timeout time.Duration
+}
+
+func newSolveLimiter(concurrency int, timeout time.Duration) *solveLimiter {
+	return &solveLimiter{
+		slots:   make(chan struct{}, concurrency),
+		timeout: timeout,
+	}
+}
+
+type solveResult struct {
+	puzzle *hectoc.Hectoc
+	err    error
+}
+
+// solve solves the problem under the spec in a free slot. A solve the
+// request gave up on still runs to the end in its slot, so abandoned
+// requests cannot pile up work.
+func (l *solveLimiter) solve(ctx context.Context, spec hectoc.Spec, problem string) (*hectoc.Hectoc, error) {
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ErrSolverBusy
+	}
+
+	done := make(chan solveResult, 1)
+
+	go func() {
+		defer func() { <-l.slots }()
+
+		puzzle, err := spec.Puzzle(problem)
+		done <- solveResult{puzzle, err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.puzzle, result.err
+	case <-ctx.Done():
+		return nil, ErrSolverBusy
+	}
+}
+
+type verifyPayload struct {
+	Problem    string       `json:"problem" validate:"required,numeric,max=8"`
+	Expression string       `json:"expression" validate:"required,max=256"`
+	Spec       *hectoc.Spec `json:"spec"`
+}
+
+type verifyResponse struct {
+	Correct bool   `json:"correct"`
+	Value   string `json:"value,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Details any    `json:"details,omitempty"`
+}
+
+type solvePayload struct {
+	Problem string       `json:"problem" validate:"required,numeric,max=6"`
+	Spec    *hectoc.Spec `json:"spec"`
+}
+
+// specOrDefault is the spec a request asked for, the classic one if none
+func specOrDefault(spec *hectoc.Spec) hectoc.Spec {
+	if spec == nil {
+		return hectoc.DefaultSpec
+	}
+
+	return *spec
+}
+
+// verifyHandler checks an answer to a puzzle, explaining why it is wrong
+func (app *application) verifyHandler(w http.ResponseWriter, r *http.Request) {
+	var payload verifyPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	spec := specOrDefault(payload.Spec)
+
+	if err := spec.Validate(); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !spec.Matches(payload.Problem) {
+		writeJSONError(w, http.StatusBadRequest, "problem does not fit the spec")
+		return
+	}
+
+	puzzle := &hectoc.Hectoc{
+		Problem: payload.Problem,
+		Spec:    spec,
+	}
+
+	correct, err := puzzle.Verify(payload.Expression)
+
+	response := &verifyResponse{Correct: correct}
+
+	if err != nil {
+		response.Error = err.Error()
+
+		var parseErr *hectoc.ParseError
+		var ruleErr *hectoc.RuleError
+		var limitErr *hectoc.LimitError
+
+		switch {
+		case errors.As(err, &parseErr):
+			response.Details = parseErr
+		case errors.As(err, &ruleErr):
+			response.Details = ruleErr
+		case errors.As(err, &limitErr):
+			response.Details = limitErr
+		}
+	} else if explanation, err := hectoc.Explain(payload.Expression); err == nil {
+		response.Value = explanation.Value
+
+		if !correct {
+			response.Details = explanation
+		}
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// solveHandler lists every solution of a puzzle along with its difficulty.
+// Puzzles are capped at MAX_SOLVE_DIGITS and solved by the app's limiter.
+func (app *application) solveHandler(w http.ResponseWriter, r *http.Request) {
+	var payload solvePayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	spec := specOrDefault(payload.Spec)
+
+	if spec.Digits > MAX_SOLVE_DIGITS {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("puzzles of more than %d digits are not solved on demand", MAX_SOLVE_DIGITS))
+		return
+	}
+
+	puzzle, err := app.solver.solve(r.Context(), spec, payload.Problem)
+
+	if errors.Is(err, ErrSolverBusy) {
+		writeJSONError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, puzzle); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// randomPuzzleHandler deals a classic puzzle from the pool, within a
+// difficulty band if one is given: easy, medium or hard
+func (app *application) randomPuzzleHandler(w http.ResponseWriter, r *http.Request) {
+	key := hectoc.DefaultPoolKey
+
+	if difficulty := r.URL.Query().Get("difficulty"); difficulty != "" {
+		band, ok := hectoc.DifficultyBands[difficulty]
+
+		if !ok {
+			writeJSONError(w, http.StatusBadRequest, "difficulty must be easy, medium or hard")
+			return
+		}
+
+		key.MinDifficulty, key.MaxDifficulty = band[0], band[1]
+	}
+
+	puzzle, err := app.hub.Pool.Get(r.Context(), key)
+
+	if err != nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "failed to generate a puzzle")
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, puzzle); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/eclairjit/hecto-clash-hf/game-server/internal/db"
 	"github.com/eclairjit/hecto-clash-hf/game-server/internal/env"
@@ -71,9 +72,51 @@ func main() {
 		config: cfg,
 		cacheStorage: cache.NewRedisStorage(rdb),
 		store: store.NewStorage(db),
+		solver: newSolveLimiter(
+			env.GetInt("SOLVE_CONCURRENCY", DEFAULT_SOLVE_CONCURRENCY),
+			env.GetDuration("SOLVE_TIMEOUT", DEFAULT_SOLVE_TIMEOUT),
+		),
 	}
 
-	hub := ws.NewHub(func (roomID string) {
+	// Puzzle bank
+	puzzles, err := app.store.Puzzles.ListSolvable(context.Background(), hectoc.DefaultSpec)
+
+	if err != nil {
+		log.Printf("Failed to load puzzle bank: %v\n", err)
+	} else if len(puzzles) > 0 {
+		entries := make([]hectoc.BankEntry, 0, len(puzzles))
+
+		for _, puzzle := range puzzles {
+			entries = append(entries, hectoc.BankEntry{
+				Problem: puzzle.Problem,
+				SolutionCount: puzzle.SolutionCount,
+				Difficulty: puzzle.Difficulty,
+			})
+		}
+
+		hectoc.UseBank(hectoc.NewBank(hectoc.DefaultSpec, entries))
+
+		log.Printf("Loaded %d puzzles into the puzzle bank.\n", len(entries))
+	} else {
+		log.Println("Puzzle bank is empty, puzzles will be solved on demand.")
+	}
+
+	// Puzzle pool
+	pool := hectoc.NewPool(hectoc.PoolConfig{
+		Workers: env.GetInt("PUZZLE_POOL_WORKERS", 2),
+		Buffer: env.GetInt("PUZZLE_POOL_BUFFER", 8),
+		MaxKeys: env.GetInt("PUZZLE_POOL_MAX_KEYS", hectoc.DEFAULT_POOL_MAX_KEYS),
+		IdleTimeout: env.GetDuration("PUZZLE_POOL_IDLE_TIMEOUT", hectoc.DEFAULT_POOL_IDLE_TIMEOUT),
+	}, hectoc.DefaultPoolKey)
+
+	pool.Start()
+	defer pool.Stop()
+
+	log.Println("Puzzle pool started.")
+
+	hub := ws.NewHub(pool,
+	
+	func (roomID string) {
 		ctx := context.Background()
 
 		if err := app.cacheStorage.Games.Delete(ctx, roomID); err != nil {
@@ -83,7 +126,7 @@ func main() {
 		}
 	}, 
 	
-	func(roomID string, puzzle *hectoc.Hectoc) {
+	func(roomID string, puzzle *hectoc.Hectoc, startedAt time.Time) {
 		ctx := context.Background()
 
 		gameID, err := app.cacheStorage.Games.Get(ctx, roomID)
@@ -96,7 +139,7 @@ func main() {
 			return
 		}
 
-		if err := app.store.Games.CreatePuzzle(ctx, gameID, puzzle); err != nil {
+		if err := app.store.Games.CreatePuzzle(ctx, gameID, puzzle, startedAt); err != nil {
 			log.Printf("Failed to set room %s in Redis: %v\n", roomID, err)
 		} else {
 			log.Printf("Set room %s in Redis\n", roomID)
@@ -118,17 +161,25 @@ func main() {
 
 		submission.GameID = gameID
 
-		if err := app.store.Submissions.Create(ctx, submission); err != nil {
+		inserted, err := app.store.Submissions.Create(ctx, submission)
+
+		if err != nil {
 			log.Printf("Failed to create submission for room %s: %v\n", roomID, err)
 			return
 		}
 
+		if !inserted {
+			log.Printf("Skipped duplicate submission for room %s\n", roomID)
+			return
+		}
+
 		log.Printf("Created submission for room %s\n", roomID)
 
 	},
 
-	func(roomID string, winnerID, loserID int64) {
+	func(roomID string, result *ws.Result) {
 		ctx := context.Background()
+		winnerID, loserID := result.WinnerID, result.LoserID
 
 		gameID, err := app.cacheStorage.Games.Get(ctx, roomID)
 
@@ -140,6 +191,28 @@ func main() {
 			return
 		}
 
+		game := &store.Game{
+			ID: gameID,
+			WinnerID: winnerID,
+			WinningSubmission: result.Submission,
+			EndReason: string(result.Reason),
+		}
+
+		// A draw has no winner
+		if result.Draw {
+			game.WinnerID = 0
+		}
+
+		if err := app.store.Games.UpdateWinnerDetails(ctx, game); err != nil {
+			log.Printf("Failed to complete game for room %s: %v\n", roomID, err)
+			return
+		}
+
+		// Practice games are recorded but leave ratings alone
+		if !result.Rated {
+			return
+		}
+
 		winnerRating, err := app.store.Ratings.GetRatingByID(ctx, winnerID)
 
 		if err != nil {
@@ -154,7 +227,7 @@ func main() {
 			return
 		}
 
-		newWinnerRating, newLoserRating := rating.GetNewRatings(winnerID, loserID, winnerID, winnerRating, loserRating)
+		newWinnerRating, newLoserRating := rating.GetNewRatings(winnerID, loserID, game.WinnerID, winnerRating, loserRating)
 
 		player1Rating := &store.Rating{
 			UserID: winnerID,
@@ -185,6 +258,41 @@ func main() {
 	},
 	)
 
+	hub.OnHint = func(roomID string, hint *store.Hint) {
+		ctx := context.Background()
+
+		gameID, err := app.cacheStorage.Games.Get(ctx, roomID)
+
+		if err == redis.Nil {
+			log.Printf("Room %s not found in Redis\n", roomID)
+			return
+		} else if err != nil {
+			log.Printf("Failed to get room %s from Redis: %v\n", roomID, err)
+			return
+		}
+
+		hint.GameID = gameID
+
+		if err := app.store.Hints.Create(ctx, hint); err != nil {
+			log.Printf("Failed to record hint for room %s: %v\n", roomID, err)
+		}
+	}
+
+	hub.Heartbeat = ws.Heartbeat{
+		PongWait: env.GetDuration("WS_PONG_WAIT", ws.DefaultHeartbeat.PongWait),
+		PingPeriod: env.GetDuration("WS_PING_PERIOD", ws.DefaultHeartbeat.PingPeriod),
+		WriteWait: env.GetDuration("WS_WRITE_WAIT", ws.DefaultHeartbeat.WriteWait),
+		MaxMessageSize: int64(env.GetInt("WS_MAX_MESSAGE_SIZE", int(ws.DefaultHeartbeat.MaxMessageSize))),
+	}
+
+	hub.ResumeGrace = env.GetDuration("WS_RESUME_GRACE", ws.DEFAULT_RESUME_GRACE)
+	hub.Countdown = env.GetDuration("WS_COUNTDOWN", ws.DEFAULT_COUNTDOWN)
+	hub.LobbyTimeout = env.GetDuration("WS_LOBBY_TIMEOUT", ws.DEFAULT_LOBBY_TIMEOUT)
+
+	if hub.Heartbeat.PingPeriod >= hub.Heartbeat.PongWait {
+		log.Fatal("WS_PING_PERIOD must be shorter than WS_PONG_WAIT")
+	}
+
 	app.hub = hub
 	
 	go hub.Run()
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"net/http"
+)
+
+func (app *application) puzzlePoolMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, app.hub.Pool.Metrics()); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (app *application) hubMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, app.hub.Metrics()); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
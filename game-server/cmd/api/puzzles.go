@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
+	"github.com/go-chi/chi/v5"
+)
+
+type puzzleSolutionsResponse struct {
+	Problem   string   `json:"problem"`
+	Format    string   `json:"format"`
+	Solutions []string `json:"solutions"`
+}
+
+// puzzleSolutionsHandler lists the solutions of a puzzle under the default
+// spec, rendered in the format asked for: text (the default), pretty, latex
+// or mathml
+func (app *application) puzzleSolutionsHandler(w http.ResponseWriter, r *http.Request) {
+	problem := chi.URLParam(r, "problem")
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = hectoc.FORMAT_TEXT
+	}
+
+	if !slices.Contains(hectoc.Formats, format) {
+		writeJSONError(w, http.StatusBadRequest, "format must be text, pretty, latex or mathml")
+		return
+	}
+
+	puzzle, err := hectoc.DefaultSpec.Puzzle(problem)
+
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := &puzzleSolutionsResponse{
+		Problem:   puzzle.Problem,
+		Format:    format,
+		Solutions: make([]string, 0, len(puzzle.Solutions)),
+	}
+
+	for _, solution := range puzzle.Solutions {
+		rendered, err := hectoc.Render(solution, format)
+
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "failed to render the solutions")
+			return
+		}
+
+		response.Solutions = append(response.Solutions, rendered)
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, response); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+	}
+}
@@ -0,0 +1,137 @@
+//go:build js && wasm
+
+// Command hectoc-wasm builds the puzzle rules of pkg/hectoc for the browser,
+// so the client checks submissions exactly as the server does. Build it with
+//
+//	GOOS=js GOARCH=wasm go build -o hectoc.wasm ./cmd/hectoc-wasm
+//
+// and load it next to Go's wasm_exec.js. It sets a global hectoc object with
+// verify(problem, expression, spec?), parse(expression),
+// explain(expression) and validateDigits(problem, expression). Each returns
+// {ok, value} or {ok: false, error: {message, details}}, where details is the
+// server's *ParseError, *RuleError or *LimitError when there is one.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"syscall/js"
+
+	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
+)
+
+type result struct {
+	OK    bool       `json:"ok"`
+	Value any        `json:"value,omitempty"`
+	Error *jsonError `json:"error,omitempty"`
+}
+
+type jsonError struct {
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+}
+
+var errArguments = errors.New("missing arguments")
+
+func main() {
+	api := js.Global().Get("Object").New()
+
+	api.Set("verify", js.FuncOf(verify))
+	api.Set("parse", js.FuncOf(parse))
+	api.Set("explain", js.FuncOf(explain))
+	api.Set("validateDigits", js.FuncOf(validateDigits))
+
+	js.Global().Set("hectoc", api)
+
+	// Keep the functions alive for the lifetime of the page
+	select {}
+}
+
+// verify checks an answer to a puzzle under the default spec, or the spec
+// passed as a third argument in the server's JSON shape
+func verify(this js.Value, args []js.Value) any {
+	if len(args) < 2 {
+		return respond(nil, errArguments)
+	}
+
+	puzzle := &hectoc.Hectoc{
+		Problem: args[0].String(),
+		Spec:    hectoc.DefaultSpec,
+	}
+
+	if len(args) > 2 && args[2].Truthy() {
+		spec := js.Global().Get("JSON").Call("stringify", args[2]).String()
+
+		if err := json.Unmarshal([]byte(spec), &puzzle.Spec); err != nil {
+			return respond(nil, err)
+		}
+	}
+
+	return respond(puzzle.Verify(args[1].String()))
+}
+
+// parse returns the expression tree of an expression
+func parse(this js.Value, args []js.Value) any {
+	if len(args) < 1 {
+		return respond(nil, errArguments)
+	}
+
+	return respond(hectoc.Parse(args[0].String()))
+}
+
+// explain returns the reductions that evaluate an expression
+func explain(this js.Value, args []js.Value) any {
+	if len(args) < 1 {
+		return respond(nil, errArguments)
+	}
+
+	return respond(hectoc.Explain(args[0].String()))
+}
+
+// validateDigits checks only that an expression uses the puzzle's digits
+// once each, in order
+func validateDigits(this js.Value, args []js.Value) any {
+	if len(args) < 2 {
+		return respond(nil, errArguments)
+	}
+
+	e, err := hectoc.Parse(args[1].String())
+
+	if err == nil {
+		err = hectoc.CheckDigits(args[0].String(), e)
+	}
+
+	return respond(err == nil, err)
+}
+
+// respond hands a result to JavaScript as a plain object
+func respond(value any, err error) any {
+	r := result{OK: err == nil}
+
+	if err != nil {
+		r.Error = &jsonError{Message: err.Error()}
+
+		var parseErr *hectoc.ParseError
+		var ruleErr *hectoc.RuleError
+		var limitErr *hectoc.LimitError
+
+		switch {
+		case errors.As(err, &parseErr):
+			r.Error.Details = parseErr
+		case errors.As(err, &ruleErr):
+			r.Error.Details = ruleErr
+		case errors.As(err, &limitErr):
+			r.Error.Details = limitErr
+		}
+	} else {
+		r.Value = value
+	}
+
+	data, err := json.Marshal(r)
+
+	if err != nil {
+		return js.Global().Get("Error").New(err.Error())
+	}
+
+	return js.Global().Get("JSON").Call("parse", string(data))
+}
@@ -0,0 +1,333 @@
+// Command hectoc runs the puzzle engine from the command line, without the
+// game server, Postgres or Redis:
+//
+//	hectoc solve 123456
+//	hectoc verify "1+(2+3+4)*(5+6)"
+//	hectoc generate -difficulty hard
+//	hectoc explain "1+2*3"
+//
+// solve and verify read one puzzle or expression per line from standard
+// input when given no arguments, to check puzzle sets in bulk. verify takes
+// the puzzle from the digits of the expression unless -problem is set, and
+// exits with status 1 if any expression is wrong. Every subcommand accepts
+// -json to print one JSON object per result, and -target and -rules to pick
+// the spec.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
+)
+
+const usage = `usage: hectoc <command> [flags] [arguments]
+
+commands:
+  solve     list the solutions of puzzles
+  verify    check answers
+  generate  deal random puzzles
+  explain   evaluate expressions step by step
+
+Run hectoc <command> -h for the flags of a command.
+`
+
+// output holds the flags every subcommand shares
+type output struct {
+	json   bool
+	target int64
+	rules  string
+}
+
+func (o *output) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.json, "json", false, "print JSON instead of text")
+	fs.Int64Var(&o.target, "target", hectoc.DefaultSpec.Target, "value the expression has to reach")
+	fs.StringVar(&o.rules, "rules", "official", "rule set: official or casual")
+}
+
+// spec is the classic spec with the target and rules asked for
+func (o *output) spec() (hectoc.Spec, error) {
+	spec := hectoc.DefaultSpec
+	spec.Target = o.target
+
+	rules, ok := hectoc.RuleSets[o.rules]
+	if !ok {
+		return spec, fmt.Errorf("unknown rules %q", o.rules)
+	}
+
+	spec.Rules = rules
+
+	return spec, nil
+}
+
+func (o *output) print(v any, text string) {
+	if o.json {
+		data, err := json.Marshal(v)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Println(text)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	commands := map[string]func([]string) error{
+		"solve":    solve,
+		"verify":   verify,
+		"generate": generate,
+		"explain":  explain,
+	}
+
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err := command(os.Args[2:]); err != nil {
+		fmt.Fprintln(os.Stderr, "hectoc:", err)
+		os.Exit(1)
+	}
+}
+
+// inputs are the arguments, or the non-empty lines of standard input if
+// there are none
+func inputs(args []string) ([]string, error) {
+	if len(args) > 0 {
+		return args, nil
+	}
+
+	var lines []string
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+func solve(args []string) error {
+	var o output
+
+	fs := flag.NewFlagSet("solve", flag.ExitOnError)
+	o.register(fs)
+	limit := fs.Int("limit", 0, "most solutions to print per puzzle, 0 for all")
+	fs.Parse(args)
+
+	spec, err := o.spec()
+	if err != nil {
+		return err
+	}
+
+	problems, err := inputs(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	for _, problem := range problems {
+		spec.Digits = len(problem)
+
+		puzzle, err := spec.Puzzle(problem)
+		if err != nil {
+			return err
+		}
+
+		solutions := puzzle.Solutions
+		if *limit > 0 && len(solutions) > *limit {
+			solutions = solutions[:*limit]
+		}
+
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "%s: %d solutions, difficulty %d", problem, len(puzzle.Solutions), puzzle.Difficulty.Score)
+
+		for _, solution := range solutions {
+			sb.WriteString("\n  " + solution)
+		}
+
+		shown := *puzzle
+		shown.Solutions = solutions
+
+		o.print(&shown, sb.String())
+	}
+
+	return nil
+}
+
+type verifyResult struct {
+	Problem    string `json:"problem"`
+	Expression string `json:"expression"`
+	Correct    bool   `json:"correct"`
+	Value      string `json:"value,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func verify(args []string) error {
+	var o output
+
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	o.register(fs)
+	problem := fs.String("problem", "", "digits of the puzzle, taken from the expression if empty")
+	fs.Parse(args)
+
+	spec, err := o.spec()
+	if err != nil {
+		return err
+	}
+
+	expressions, err := inputs(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	wrong := 0
+
+	for _, expression := range expressions {
+		result := &verifyResult{
+			Problem:    *problem,
+			Expression: expression,
+		}
+
+		if result.Problem == "" {
+			result.Problem = digitsOf(expression)
+		}
+
+		puzzle := &hectoc.Hectoc{
+			Problem: result.Problem,
+			Spec:    spec,
+		}
+
+		result.Correct, err = puzzle.Verify(expression)
+
+		if err != nil {
+			result.Error = err.Error()
+		} else if explanation, err := hectoc.Explain(expression); err == nil {
+			result.Value = explanation.Value
+		}
+
+		text := fmt.Sprintf("%s: correct", expression)
+
+		switch {
+		case result.Error != "":
+			text = fmt.Sprintf("%s: invalid: %s", expression, result.Error)
+		case !result.Correct:
+			text = fmt.Sprintf("%s: wrong, evaluates to %s, not %d", expression, result.Value, spec.Target)
+		}
+
+		if !result.Correct {
+			wrong++
+		}
+
+		o.print(result, text)
+	}
+
+	if wrong > 0 {
+		return fmt.Errorf("%d of %d expressions are not correct", wrong, len(expressions))
+	}
+
+	return nil
+}
+
+// digitsOf lists the digits of an expression in order
+func digitsOf(expression string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, expression)
+}
+
+func generate(args []string) error {
+	var o output
+
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	o.register(fs)
+	difficulty := fs.String("difficulty", "", "easy, medium or hard; any difficulty if empty")
+	count := fs.Int("count", 1, "number of puzzles to deal")
+	seed := fs.Int64("seed", 0, "seed for reproducible puzzles; random if 0")
+	fs.Parse(args)
+
+	spec, err := o.spec()
+	if err != nil {
+		return err
+	}
+
+	band := [2]int{0, hectoc.MAX_DIFFICULTY}
+
+	if *difficulty != "" {
+		var ok bool
+
+		if band, ok = hectoc.DifficultyBands[*difficulty]; !ok {
+			return errors.New("difficulty must be easy, medium or hard")
+		}
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	generator := hectoc.NewSeededGenerator(spec, *seed)
+
+	for i := 0; i < *count; i++ {
+		puzzle, err := generator.GenerateWithDifficulty(band[0], band[1])
+		if err != nil {
+			return err
+		}
+
+		o.print(puzzle, fmt.Sprintf("%s: %d solutions, difficulty %d", puzzle.Problem, len(puzzle.Solutions), puzzle.Difficulty.Score))
+	}
+
+	return nil
+}
+
+func explain(args []string) error {
+	var o output
+
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	o.register(fs)
+	fs.Parse(args)
+
+	expressions, err := inputs(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	for _, expression := range expressions {
+		explanation, err := hectoc.Explain(expression)
+		if err != nil {
+			return fmt.Errorf("%s: %w", expression, err)
+		}
+
+		var sb strings.Builder
+		sb.WriteString(expression)
+
+		for _, step := range explanation.Steps {
+			sb.WriteString("\n  " + step.String())
+		}
+
+		sb.WriteString("\n  = " + explanation.Value)
+
+		o.print(explanation, sb.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE submissions
+ADD COLUMN canonical_submission TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_submissions_canonical
+ON submissions (game_id, player_id, canonical_submission);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS uq_submissions_canonical;
+
+ALTER TABLE submissions
+DROP COLUMN canonical_submission;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE games
+DROP CONSTRAINT IF EXISTS games_hectoc_puzzle_check;
+
+ALTER TABLE games
+ALTER COLUMN hectoc_puzzle TYPE VARCHAR(16);
+
+ALTER TABLE games
+ADD CONSTRAINT games_hectoc_puzzle_check CHECK (hectoc_puzzle ~ '^[0-9]{1,16}$');
+
+ALTER TABLE games
+ADD COLUMN target BIGINT NOT NULL DEFAULT 100;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE games
+DROP COLUMN target;
+
+ALTER TABLE games
+DROP CONSTRAINT IF EXISTS games_hectoc_puzzle_check;
+
+ALTER TABLE games
+ALTER COLUMN hectoc_puzzle TYPE CHAR(6);
+
+ALTER TABLE games
+ADD CONSTRAINT games_hectoc_puzzle_check CHECK (hectoc_puzzle ~ '^[1-9]{6}$');
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS puzzles (
+    problem VARCHAR(16) NOT NULL CHECK (problem ~ '^[0-9]{1,16}$'),
+    target BIGINT NOT NULL DEFAULT 100,
+    alphabet VARCHAR(10) NOT NULL DEFAULT '123456789' CHECK (alphabet ~ '^[0-9]{1,10}$'),
+    rules JSONB NOT NULL,
+    solvable BOOLEAN NOT NULL,
+    solution_count INT NOT NULL CHECK (solution_count >= 0),
+    difficulty INT NOT NULL CHECK (difficulty >= 0),
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CONSTRAINT pk_puzzles PRIMARY KEY (problem, target, alphabet, rules)
+);
+
+CREATE INDEX IF NOT EXISTS idx_puzzles_target_difficulty
+ON puzzles (target, alphabet, difficulty)
+WHERE solvable;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS puzzles;
+-- +goose StatementEnd
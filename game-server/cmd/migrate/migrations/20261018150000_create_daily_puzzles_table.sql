@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS daily_puzzles (
+    puzzle_date DATE PRIMARY KEY,
+    problem VARCHAR(16) NOT NULL CHECK (problem ~ '^[0-9]{1,16}$'),
+    target BIGINT NOT NULL DEFAULT 100,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS daily_puzzles;
+-- +goose StatementEnd
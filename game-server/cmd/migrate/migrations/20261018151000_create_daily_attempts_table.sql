@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS daily_attempts (
+    puzzle_date DATE NOT NULL REFERENCES daily_puzzles (puzzle_date) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    solution TEXT,
+    started_at TIMESTAMP(3) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    solved_at TIMESTAMP(3) WITH TIME ZONE,
+    CONSTRAINT pk_daily_attempts PRIMARY KEY (puzzle_date, user_id),
+    CONSTRAINT chk_daily_attempts_solved CHECK ((solved_at IS NULL) = (solution IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_daily_attempts_solve_time
+ON daily_attempts (puzzle_date, (solved_at - started_at))
+WHERE solved_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS daily_attempts;
+-- +goose StatementEnd
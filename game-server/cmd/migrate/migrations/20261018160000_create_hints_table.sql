@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS hints (
+    id BIGSERIAL PRIMARY KEY,
+    game_id BIGINT NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+    player_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    partial TEXT NOT NULL,
+    level SMALLINT NOT NULL,
+    penalty_ms BIGINT NOT NULL,
+    requested_at TIMESTAMP(3) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_hints_game_player
+ON hints (game_id, player_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS hints;
+-- +goose StatementEnd
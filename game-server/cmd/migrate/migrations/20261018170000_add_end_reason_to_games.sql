@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE games
+ADD COLUMN end_reason VARCHAR(16) CHECK (end_reason IN ('solved', 'forfeit'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE games
+DROP COLUMN end_reason;
+-- +goose StatementEnd
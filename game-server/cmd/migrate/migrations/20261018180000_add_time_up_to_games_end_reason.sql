@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE games
+DROP CONSTRAINT IF EXISTS games_end_reason_check;
+
+ALTER TABLE games
+ADD CONSTRAINT games_end_reason_check CHECK (end_reason IN ('solved', 'forfeit', 'time_up'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE games
+DROP CONSTRAINT IF EXISTS games_end_reason_check;
+
+ALTER TABLE games
+ADD CONSTRAINT games_end_reason_check CHECK (end_reason IN ('solved', 'forfeit'));
+-- +goose StatementEnd
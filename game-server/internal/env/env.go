@@ -3,6 +3,7 @@ package env
 import (
 	"os"
 	"strconv"
+	"time"
 )
 
 func GetString(key, fallback string) string {
@@ -45,4 +46,20 @@ func GetBool(key string, fallback bool) bool {
 	}
 	
 	return boolVal
-}
\ No newline at end of file
+}
+
+func GetDuration(key string, fallback time.Duration) time.Duration {
+	val, ok := os.LookupEnv(key)
+
+	if !ok {
+		return fallback
+	}
+
+	durationVal, err := time.ParseDuration(val)
+
+	if err != nil {
+		return fallback
+	}
+
+	return durationVal
+}
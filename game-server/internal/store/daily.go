@@ -0,0 +1,195 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+type DailyStore struct {
+	db *sql.DB
+}
+
+// DailyPuzzle is the puzzle everyone gets on a UTC date (YYYY-MM-DD)
+type DailyPuzzle struct {
+	Date string `json:"date"`
+	Problem string `json:"problem"`
+	Target int64 `json:"target"`
+	CreatedAt string `json:"created_at"`
+}
+
+// DailyAttempt is one player's run at a daily puzzle. The clock starts when
+// the player first fetches the puzzle.
+type DailyAttempt struct {
+	Date string `json:"date"`
+	UserID int64 `json:"user_id"`
+	Solution string `json:"solution,omitempty"`
+	StartedAt string `json:"started_at"`
+	SolvedAt string `json:"solved_at,omitempty"`
+	SolveTimeMs int64 `json:"solve_time_ms,omitempty"`
+}
+
+// DailyResult is a row of a daily puzzle's leaderboard
+type DailyResult struct {
+	Rank int `json:"rank"`
+	UserID int64 `json:"user_id"`
+	Username string `json:"username"`
+	SolveTimeMs int64 `json:"solve_time_ms"`
+	SolvedAt string `json:"solved_at"`
+}
+
+// GetOrCreatePuzzle stores the puzzle for its date unless one already
+// exists, and fills in whichever puzzle the date ends up with
+func (s *DailyStore) GetOrCreatePuzzle(ctx context.Context, puzzle *DailyPuzzle) error {
+	query := `
+		INSERT INTO daily_puzzles (puzzle_date, problem, target)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (puzzle_date) DO UPDATE
+		SET puzzle_date = EXCLUDED.puzzle_date
+		RETURNING problem, target, created_at;
+	`
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		puzzle.Date,
+		puzzle.Problem,
+		puzzle.Target,
+	).Scan(&puzzle.Problem, &puzzle.Target, &puzzle.CreatedAt)
+}
+
+// StartAttempt starts the player's clock for the date unless it is already
+// running, and fills in the attempt as stored
+func (s *DailyStore) StartAttempt(ctx context.Context, attempt *DailyAttempt) error {
+	query := `
+		INSERT INTO daily_attempts (puzzle_date, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (puzzle_date, user_id) DO UPDATE
+		SET user_id = EXCLUDED.user_id
+		RETURNING started_at;
+	`
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		attempt.Date,
+		attempt.UserID,
+	).Scan(&attempt.StartedAt)
+
+	if err != nil {
+		return err
+	}
+
+	return s.GetAttempt(ctx, attempt)
+}
+
+// GetAttempt fills in the player's attempt for the date
+func (s *DailyStore) GetAttempt(ctx context.Context, attempt *DailyAttempt) error {
+	query := `
+		SELECT solution, started_at, solved_at,
+			COALESCE((EXTRACT(EPOCH FROM solved_at - started_at) * 1000)::BIGINT, 0)
+		FROM daily_attempts
+		WHERE puzzle_date = $1 AND user_id = $2;
+	`
+
+	var solution, solvedAt sql.NullString
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		attempt.Date,
+		attempt.UserID,
+	).Scan(&solution, &attempt.StartedAt, &solvedAt, &attempt.SolveTimeMs)
+
+	if err != nil {
+		switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+		}
+	}
+
+	attempt.Solution = solution.String
+	attempt.SolvedAt = solvedAt.String
+
+	return nil
+}
+
+// SolveAttempt stops the player's clock with a correct solution. It returns
+// ErrNotFound if the attempt was never started or is already solved.
+func (s *DailyStore) SolveAttempt(ctx context.Context, attempt *DailyAttempt) error {
+	query := `
+		UPDATE daily_attempts
+		SET solution = $1, solved_at = NOW()
+		WHERE puzzle_date = $2 AND user_id = $3 AND solved_at IS NULL
+		RETURNING started_at, solved_at,
+			(EXTRACT(EPOCH FROM solved_at - started_at) * 1000)::BIGINT;
+	`
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		attempt.Solution,
+		attempt.Date,
+		attempt.UserID,
+	).Scan(&attempt.StartedAt, &attempt.SolvedAt, &attempt.SolveTimeMs)
+
+	if err != nil {
+		switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrNotFound
+			default:
+				return err
+		}
+	}
+
+	return nil
+}
+
+// Leaderboard returns the fastest solvers of the date's puzzle
+func (s *DailyStore) Leaderboard(ctx context.Context, date string, limit int) ([]*DailyResult, error) {
+	query := `
+		SELECT a.user_id, u.username,
+			(EXTRACT(EPOCH FROM a.solved_at - a.started_at) * 1000)::BIGINT AS solve_time_ms,
+			a.solved_at
+		FROM daily_attempts a
+		JOIN users u ON u.id = a.user_id
+		WHERE a.puzzle_date = $1 AND a.solved_at IS NOT NULL
+		ORDER BY solve_time_ms, a.solved_at
+		LIMIT $2;
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, date, limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	results := []*DailyResult{}
+
+	for rows.Next() {
+		result := &DailyResult{Rank: len(results) + 1}
+
+		err := rows.Scan(
+			&result.UserID,
+			&result.Username,
+			&result.SolveTimeMs,
+			&result.SolvedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, result)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
 	"github.com/lib/pq"
@@ -31,18 +32,23 @@ type Game struct {
 	ID int64 `json:"id"`
 	RoomID string `json:"room_id"`
 	HectocPuzzle string `json:"hectoc_puzzle"`
+	Target int64 `json:"target"`
 	WinnerID int64 `json:"winner_id"`
 	WinningSubmission string `json:"winning_submission"`
 	CorrectSolution []string `json:"correct_solution"`
 	GameState string `json:"game_state"`
+	EndReason string `json:"end_reason"`
+	StartedAt string `json:"started_at"`
 	CreatedAt string `json:"created_at"`
 }
 
-func (s *GameStore) CreatePuzzle(ctx context.Context, gameID int64, puzzle *hectoc.Hectoc) error {
+// CreatePuzzle records the puzzle of a game and the instant it was revealed
+// to the players
+func (s *GameStore) CreatePuzzle(ctx context.Context, gameID int64, puzzle *hectoc.Hectoc, startedAt time.Time) error {
 	query := `
 		UPDATE games
-		SET hectoc_puzzle = $1, game_state = $2, correct_solutions = $3
-		WHERE id = $4
+		SET hectoc_puzzle = $1, game_state = $2, correct_solutions = $3, target = $4, started_at = $5
+		WHERE id = $6
 		RETURNING game_state, created_at;
 	`
 	result, err := s.db.ExecContext(
@@ -51,6 +57,8 @@ func (s *GameStore) CreatePuzzle(ctx context.Context, gameID int64, puzzle *hect
 		puzzle.Problem,
 		STATUS_IN_PROGRESS,
 		pq.Array(puzzle.Solutions),
+		puzzle.Spec.Target,
+		startedAt,
 		gameID,
 	)
 
@@ -76,17 +84,21 @@ func (s *GameStore) CreatePuzzle(ctx context.Context, gameID int64, puzzle *hect
 	return nil
 }
 
+// UpdateWinnerDetails records how a game ended and marks it completed. A
+// game without a winner, a draw, is stored with a NULL winner_id.
 func (s *GameStore) UpdateWinnerDetails(ctx context.Context, game *Game) error {
 	query := `
 		UPDATE games
-		SET winner_id = $1, winning_submission = $2
-		WHERE id = $3;
+		SET winner_id = $1, winning_submission = $2, end_reason = $3, game_state = $4
+		WHERE id = $5;
 	`
 	res, err := s.db.ExecContext(
 		ctx,
 		query,
-		game.WinnerID,
+		sql.NullInt64{Int64: game.WinnerID, Valid: game.WinnerID != 0},
 		game.WinningSubmission,
+		game.EndReason,
+		STATUS_COMPLETED,
 		game.ID,
 	)
 
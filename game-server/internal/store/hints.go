@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type HintStore struct {
+	db *sql.DB
+}
+
+// Hint is a hint a player asked for in a practice game, with the time it
+// costs them. Hints have their own table, since a player may ask for several
+// within the same second.
+type Hint struct {
+	ID int64 `json:"id"`
+	GameID int64 `json:"game_id"`
+	PlayerID int64 `json:"player_id"`
+	// Partial is the expression the player had when they asked
+	Partial string `json:"partial"`
+	Level int `json:"level"`
+	PenaltyMs int64 `json:"penalty_ms"`
+	RequestedAt string `json:"requested_at"`
+}
+
+func (s *HintStore) Create(ctx context.Context, hint *Hint) error {
+	query := `
+		INSERT INTO hints (game_id, player_id, partial, level, penalty_ms)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, requested_at;
+	`
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		hint.GameID,
+		hint.PlayerID,
+		hint.Partial,
+		hint.Level,
+		hint.PenaltyMs,
+	).Scan(&hint.ID, &hint.RequestedAt)
+
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -15,10 +15,14 @@ type Player struct {
 	CreatedAt string `json:"created_at"`
 }
 
+// Create adds a player to a game. A player rejoining the same game, say after
+// a reconnect, keeps their original row.
 func (s *PlayerStore) Create(ctx context.Context, player *Player) error {
 	query := `
 		INSERT INTO players (game_id, player_id)
 		VALUES ($1, $2)
+		ON CONFLICT (game_id, player_id) DO UPDATE
+		SET game_id = EXCLUDED.game_id
 		RETURNING created_at;
 	`
 
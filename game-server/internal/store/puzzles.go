@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
+)
+
+type PuzzleStore struct {
+	db *sql.DB
+}
+
+type Puzzle struct {
+	Problem string `json:"problem"`
+	Target int64 `json:"target"`
+	Alphabet string `json:"alphabet"`
+	Rules hectoc.Rules `json:"rules"`
+	Solvable bool `json:"solvable"`
+	SolutionCount int `json:"solution_count"`
+	Difficulty int `json:"difficulty"`
+	CreatedAt string `json:"created_at"`
+}
+
+// CreateBatch stores a batch of puzzles in one transaction, replacing any
+// earlier results for the same problem, target, alphabet and rules
+func (s *PuzzleStore) CreateBatch(ctx context.Context, puzzles []*Puzzle) error {
+	query := `
+		INSERT INTO puzzles (problem, target, alphabet, rules, solvable, solution_count, difficulty)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (problem, target, alphabet, rules) DO UPDATE
+		SET solvable = EXCLUDED.solvable,
+			solution_count = EXCLUDED.solution_count,
+			difficulty = EXCLUDED.difficulty;
+	`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+
+	if err != nil {
+		return err
+	}
+
+	defer stmt.Close()
+
+	for _, puzzle := range puzzles {
+		// lib/pq would send []byte as bytea, so the rules go as text
+		rules, err := json.Marshal(puzzle.Rules)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = stmt.ExecContext(
+			ctx,
+			puzzle.Problem,
+			puzzle.Target,
+			puzzle.Alphabet,
+			string(rules),
+			puzzle.Solvable,
+			puzzle.SolutionCount,
+			puzzle.Difficulty,
+		)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ListSolvable returns every solvable puzzle with the spec's digit count,
+// target, alphabet and rules, easiest first
+func (s *PuzzleStore) ListSolvable(ctx context.Context, spec hectoc.Spec) ([]*Puzzle, error) {
+	query := `
+		SELECT problem, target, alphabet, rules, solvable, solution_count, difficulty, created_at
+		FROM puzzles
+		WHERE solvable AND target = $1 AND length(problem) = $2 AND alphabet = $3 AND rules = $4::jsonb
+		ORDER BY difficulty;
+	`
+
+	rules, err := json.Marshal(spec.Rules)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, spec.Target, spec.Digits, spec.Alphabet, string(rules))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var puzzles []*Puzzle
+
+	for rows.Next() {
+		puzzle := &Puzzle{}
+		var rules []byte
+
+		err := rows.Scan(
+			&puzzle.Problem,
+			&puzzle.Target,
+			&puzzle.Alphabet,
+			&rules,
+			&puzzle.Solvable,
+			&puzzle.SolutionCount,
+			&puzzle.Difficulty,
+			&puzzle.CreatedAt,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal(rules, &puzzle.Rules); err != nil {
+			return nil, err
+		}
+
+		puzzles = append(puzzles, puzzle)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return puzzles, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"time"
 
 	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
 )
@@ -18,18 +19,35 @@ type Storage struct {
 	}
 
 	Games interface {
-		CreatePuzzle(context.Context, int64, *hectoc.Hectoc) error
+		CreatePuzzle(context.Context, int64, *hectoc.Hectoc, time.Time) error
 		UpdateWinnerDetails(context.Context, *Game) error
 	}
 
 	Submissions interface {
-		Create(context.Context, *SubmissionStruct) error
+		Create(context.Context, *SubmissionStruct) (bool, error)
+	}
+
+	Hints interface {
+		Create(context.Context, *Hint) error
 	}
 
 	Ratings interface {
 		UpdateRatings(context.Context, *Rating, *Rating) error
 		GetRatingByID(context.Context, int64) (int, error)
 	}
+
+	Puzzles interface {
+		CreateBatch(context.Context, []*Puzzle) error
+		ListSolvable(context.Context, hectoc.Spec) ([]*Puzzle, error)
+	}
+
+	Daily interface {
+		GetOrCreatePuzzle(context.Context, *DailyPuzzle) error
+		StartAttempt(context.Context, *DailyAttempt) error
+		GetAttempt(context.Context, *DailyAttempt) error
+		SolveAttempt(context.Context, *DailyAttempt) error
+		Leaderboard(context.Context, string, int) ([]*DailyResult, error)
+	}
 }
 
 func NewStorage(db *sql.DB) Storage {
@@ -37,6 +55,9 @@ func NewStorage(db *sql.DB) Storage {
 		Players: &PlayerStore{db},
 		Games: &GameStore{db},
 		Submissions: &SubmissionStore{db},
+		Hints: &HintStore{db},
 		Ratings: &RatingStore{db},
+		Puzzles: &PuzzleStore{db},
+		Daily: &DailyStore{db},
 	}
 }
\ No newline at end of file
@@ -13,14 +13,19 @@ type SubmissionStruct struct {
 	GameID int64  `json:"game_id"`
 	PlayerID int64 `json:"player_id"`
 	Submission string `json:"submission"`
+	CanonicalSubmission string `json:"canonical_submission"`
 	IsCorrect bool `json:"is_correct"`
 	SubmittedAt string `json:"submitted_at"`
 }
 
-func (s *SubmissionStore) Create(ctx context.Context, submission *SubmissionStruct) error {
+// Create stores a submission and reports whether it was inserted. A
+// submission equivalent to one the player has already made in the same game
+// is collapsed into the earlier row, and reported as not inserted.
+func (s *SubmissionStore) Create(ctx context.Context, submission *SubmissionStruct) (bool, error) {
 	query := `
-		INSERT INTO submissions (game_id, player_id, submission, is_correct)
-		VALUES ($1, $2, $3, $4);
+		INSERT INTO submissions (game_id, player_id, submission, is_correct, canonical_submission)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		ON CONFLICT (game_id, player_id, canonical_submission) DO NOTHING;
 	`
 	result, err := s.db.ExecContext(
 		ctx,
@@ -29,21 +34,18 @@ func (s *SubmissionStore) Create(ctx context.Context, submission *SubmissionStru
 		submission.PlayerID,
 		submission.Submission,
 		submission.IsCorrect,
+		submission.CanonicalSubmission,
 	)
 
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	rowsAffected, err := result.RowsAffected()
 
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
-	}
-
-	return nil
+	return rowsAffected > 0, nil
 }
\ No newline at end of file
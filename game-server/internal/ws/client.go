@@ -3,15 +3,59 @@ package ws
 import (
 	"encoding/json"
 	"log"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// Delivery policy for outgoing messages. Rooms never wait on a client: a
+// message that does not fit in the client's queue is dropped, and a client
+// whose queue stays above the high-water mark is disconnected.
+const (
+	// CLIENT_QUEUE_SIZE is the buffer clients' Message channels are made with
+	CLIENT_QUEUE_SIZE = 32
+	// CLIENT_HIGH_WATER is the queue length at which a client counts as slow
+	CLIENT_HIGH_WATER = 24
+	// SLOW_CLIENT_TIMEOUT is how long a client may stay slow, unless the hub
+	// says otherwise
+	SLOW_CLIENT_TIMEOUT = 5 * time.Second
+)
+
+// Heartbeat configures the keepalives and limits of client connections. The
+// server pings every PingPeriod; a connection that sends nothing, not even a
+// pong, for PongWait is considered lost.
+type Heartbeat struct {
+	PongWait   time.Duration
+	PingPeriod time.Duration
+	// WriteWait bounds a single write to the connection
+	WriteWait time.Duration
+	// MaxMessageSize is the largest message accepted from a client, in bytes
+	MaxMessageSize int64
+}
+
+// DefaultHeartbeat pings well within the minute most proxies allow idle
+// connections
+var DefaultHeartbeat = Heartbeat{
+	PongWait:       60 * time.Second,
+	PingPeriod:     54 * time.Second,
+	WriteWait:      10 * time.Second,
+	MaxMessageSize: 4096,
+}
+
 type Client struct {
 	Conn     *websocket.Conn
 	Message  chan *Message
 	ID       string `json:"id"`
 	RoomID   string `json:"roomId"`
+	// Settings requested for the room, applied only if this client creates it
+	Settings RoomSettings `json:"settings"`
+	// ResumeToken reclaims a seat held after a dropped connection
+	ResumeToken string `json:"-"`
+
+	// Delivery state, owned by the goroutine of the client's room
+	dropped   int
+	slowSince time.Time
+	slow      bool
 }
 
 type MessageType string
@@ -29,6 +73,17 @@ const (
 	MESSAGE_TYPE_WRONG_SUBMISSION  	MessageType = "wrong_submission"
 	MESSAGE_TYPE_PUZZLE_ASSIGN 		MessageType = "puzzle_assign"
 	MESSAGE_TYPE_CORRECT_SUBMISSION  MessageType = "correct_submission"
+	MESSAGE_TYPE_HINT_REQUEST 		MessageType = "hint_request"
+	MESSAGE_TYPE_HINT 				MessageType = "hint"
+	MESSAGE_TYPE_CONNECTION_LOST 	MessageType = "connection_lost"
+	MESSAGE_TYPE_RESUMED 			MessageType = "resumed"
+	MESSAGE_TYPE_OPPONENT_RESUMED 	MessageType = "opponent_resumed"
+	MESSAGE_TYPE_FORFEIT 			MessageType = "forfeit"
+	MESSAGE_TYPE_TIME_UP 			MessageType = "time_up"
+	MESSAGE_TYPE_OPPONENT_JOINED 	MessageType = "opponent_joined"
+	MESSAGE_TYPE_READY 				MessageType = "ready"
+	MESSAGE_TYPE_COUNTDOWN 			MessageType = "countdown"
+	MESSAGE_TYPE_LOBBY_TIMEOUT 		MessageType = "lobby_timeout"
 )
 
 type Message struct {
@@ -36,38 +91,87 @@ type Message struct {
 	Content   any `json:"content"`
 	RoomID    string      `json:"roomId"`
 	SenderID  string      `json:"senderId"`
+	// Details carries structured data alongside Content, such as the
+	// position of a parse error in a submission
+	Details   any         `json:"details,omitempty"`
 }
 
-func (c *Client) WriteMessage() {
+// WriteMessage sends the client's messages and pings until its room closes
+// the channel or a write fails
+func (c *Client) WriteMessage(hub *Hub) {
+	heartbeat := hub.Heartbeat
+	ticker := time.NewTicker(heartbeat.PingPeriod)
+
 	defer func() {
+		ticker.Stop()
 		c.Conn.Close()
 	}()
 
 	for {
-		message, ok := <-c.Message
-		if !ok {
-			return
-		}
+		select {
+		case message, ok := <-c.Message:
+			// A connection that cannot take a message in time is dead;
+			// closing it ends ReadMessage, which reports the loss
+			c.Conn.SetWriteDeadline(time.Now().Add(heartbeat.WriteWait))
+
+			if !ok {
+				c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+
+			if err := c.Conn.WriteJSON(message); err != nil {
+				log.Printf("error: writing to client %s: %v", c.ID, err)
+				return
+			}
 
-		c.Conn.WriteJSON(message)
+		case <-ticker.C:
+			c.Conn.SetWriteDeadline(time.Now().Add(heartbeat.WriteWait))
+
+			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
 	}
 }
 
+// ReadMessage passes the client's messages to the hub until the connection
+// closes. A client that says goodbye leaves its room; one that goes silent,
+// drops without a close frame or goes away, as a reloading page does, has
+// lost its connection and may resume.
 func (c *Client) ReadMessage(hub *Hub) {
+	heartbeat := hub.Heartbeat
+	lost := true
+
 	defer func() {
-		hub.Unregister <- c
+		if lost {
+			hub.messages <- roomEvent{kind: ROOM_EVENT_LOST, client: c}
+		} else {
+			hub.Unregister <- c
+		}
+
 		c.Conn.Close()
 	}()
 
+	c.Conn.SetReadLimit(heartbeat.MaxMessageSize)
+	c.Conn.SetReadDeadline(time.Now().Add(heartbeat.PongWait))
+	c.Conn.SetPongHandler(func(string) error {
+		return c.Conn.SetReadDeadline(time.Now().Add(heartbeat.PongWait))
+	})
+
 	for {
 		_, m, err := c.Conn.ReadMessage()
 		if err != nil {
+			lost = !websocket.IsCloseError(err, websocket.CloseNormalClosure)
+
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				log.Printf("error: %v", err)
 			}
 			break
 		}
 
+		// Any message shows the connection is alive
+		c.Conn.SetReadDeadline(time.Now().Add(heartbeat.PongWait))
+
 		var msg Message
 		if err := json.Unmarshal(m, &msg); err != nil {
 			log.Printf("error: %v", err)
@@ -75,14 +179,15 @@ func (c *Client) ReadMessage(hub *Hub) {
 		}
 
 		switch msg.Type {
-		case MESSAGE_TYPE_SUBMIT:
-			hub.handleSubmission(c, msg)
-
 		case MESSAGE_TYPE_LEAVE:
 			hub.Unregister <- c
 
 		default:
-			hub.Broadcast <- &msg
+			// Messages always act on the sender's own room
+			msg.RoomID = c.RoomID
+			msg.SenderID = c.ID
+
+			hub.messages <- roomEvent{kind: ROOM_EVENT_MESSAGE, client: c, msg: &msg}
 		}
 	}
-}
\ No newline at end of file
+}
@@ -1,41 +1,133 @@
 package ws
 
 import (
+	"sync/atomic"
+	"time"
+
 	"github.com/eclairjit/hecto-clash-hf/game-server/internal/store"
 	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
 )
 
-type Room struct {
-	ID      string             `json:"id"`
-	Clients map[string]*Client `json:"clients"`
-    Puzzle  *hectoc.Hectoc     `json:"puzzle"`
+// RoomSettings are chosen by the client that creates a room
+type RoomSettings struct {
+	Spec          hectoc.Spec `json:"spec"`
+	MinDifficulty int         `json:"minDifficulty"`
+	MaxDifficulty int         `json:"maxDifficulty"`
+	// Practice rooms are unrated and let players ask for hints
+	Practice bool `json:"practice"`
+	// HintPenalty is how many seconds each hint adds to a player's time:
+	// they cannot submit until it has run out
+	HintPenalty int `json:"hintPenalty"`
+	// TimeLimit is how many seconds the players have to solve the puzzle
+	// before the game is drawn; zero means no limit
+	TimeLimit int `json:"timeLimit"`
+}
+
+// DEFAULT_HINT_PENALTY is the time in seconds a hint costs unless the room
+// says otherwise
+const DEFAULT_HINT_PENALTY = 30
+
+// DEFAULT_TIME_LIMIT is the time in seconds a game lasts unless the room
+// says otherwise
+const DEFAULT_TIME_LIMIT = 300
+
+// DefaultRoomSettings are used when a client asks for nothing in particular
+var DefaultRoomSettings = RoomSettings{
+	Spec:          hectoc.DefaultSpec,
+	MinDifficulty: 0,
+	MaxDifficulty: hectoc.MAX_DIFFICULTY,
+	HintPenalty:   DEFAULT_HINT_PENALTY,
+	TimeLimit:     DEFAULT_TIME_LIMIT,
+}
+
+// poolKey is the stream of pooled puzzles that fit the room's settings
+func (s RoomSettings) poolKey() hectoc.PoolKey {
+	return hectoc.PoolKey{
+		Spec:          s.Spec,
+		MinDifficulty: s.MinDifficulty,
+		MaxDifficulty: s.MaxDifficulty,
+	}
 }
 
+// PUZZLE_WAIT_TIMEOUT bounds how long a full room waits for the pool when no
+// puzzle was ready
+const PUZZLE_WAIT_TIMEOUT = 30 * time.Second
+
+// Hub routes clients and their messages to rooms. Every room is run by its
+// own goroutine, which alone reads and writes the room's state and its
+// clients' channels; the hub only owns the map of rooms and never waits on
+// one. The On* callbacks reach the store, so every room runs them on a
+// goroutine of its own rather than the one playing the game.
 type Hub struct {
 	Rooms       map[string]*Room
 	Register    chan *Client
 	Unregister  chan *Client
 	Broadcast   chan *Message
+    Pool        *hectoc.Pool
+    messages    chan roomEvent
+    emptied     chan *Room
     OnRoomEmpty func(roomID string)
-    OnPuzzleCreated func(roomID string, puzzle *hectoc.Hectoc)
+    OnPuzzleCreated func(roomID string, puzzle *hectoc.Hectoc, startedAt time.Time)
     OnSubmission func(roomID string, submission *store.SubmissionStruct)
-    OnEnding func(roomID string, winnerID int64, loserID int64)
+    OnEnding func(roomID string, result *Result)
+    // OnHint records a hint given in a practice room
+    OnHint func(roomID string, hint *store.Hint)
+    // SlowClientTimeout is how long a client may stay above the high-water
+    // mark before it is disconnected
+    SlowClientTimeout time.Duration
+    // Heartbeat configures keepalives on client connections
+    Heartbeat Heartbeat
+    // ResumeGrace is how long a player whose connection dropped keeps their
+    // seat; zero drops them at once
+    ResumeGrace time.Duration
+    // Countdown is how long after both players are ready the puzzle is
+    // revealed
+    Countdown time.Duration
+    // LobbyTimeout is how long a full room waits for both players to be
+    // ready before it is called off; zero waits for good
+    LobbyTimeout time.Duration
+
+    rooms     atomic.Int64
+    delivered atomic.Uint64
+    dropped   atomic.Uint64
+    evicted   atomic.Uint64
+    busy      atomic.Uint64
+}
+
+// HubMetrics is a snapshot of the hub's delivery counters
+type HubMetrics struct {
+	Rooms     int    `json:"rooms"`
+	Delivered uint64 `json:"delivered"`
+	Dropped   uint64 `json:"dropped"`
+	Evicted   uint64 `json:"evicted"`
+	// Busy counts client messages dropped because their room was too far
+	// behind to take them
+	Busy uint64 `json:"busy"`
 }
 
 func NewHub(
+    pool *hectoc.Pool,
     onRoomEmpty func(roomID string),
-    onPuzzleCreated func(roomID string, puzzle *hectoc.Hectoc),
+    onPuzzleCreated func(roomID string, puzzle *hectoc.Hectoc, startedAt time.Time),
     onSubmission func(roomID string, submission *store.SubmissionStruct),
-    onEnding func(roomID string, winnerID int64, loserID int64)) *Hub{
+    onEnding func(roomID string, result *Result)) *Hub{
 	return &Hub{
 		Rooms:      make(map[string]*Room),
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
 		Broadcast:  make(chan *Message, 5),
+        Pool:       pool,
+        messages:   make(chan roomEvent),
+        emptied:    make(chan *Room),
         OnRoomEmpty: onRoomEmpty,
         OnPuzzleCreated: onPuzzleCreated,
         OnSubmission: onSubmission,
         OnEnding: onEnding,
+        SlowClientTimeout: SLOW_CLIENT_TIMEOUT,
+        Heartbeat: DefaultHeartbeat,
+        ResumeGrace: DEFAULT_RESUME_GRACE,
+        Countdown: DEFAULT_COUNTDOWN,
+        LobbyTimeout: DEFAULT_LOBBY_TIMEOUT,
 	}
 }
 
@@ -43,111 +135,73 @@ func (h *Hub) Run() {
     for {
         select {
         case cl := <-h.Register:
-            // Check if the room exists
-            if room, ok := h.Rooms[cl.RoomID]; ok {
-                // Check if the room already has 2 clients
-                if len(room.Clients) >= 2 {
-                    // Notify the client that the room is full
-                    cl.Message <- &Message{
-                        Type:    MESSAGE_TYPE_ROOM_FULL,
-                        Content: "Room is full",
-                        RoomID:  cl.RoomID,
-                    }
-                    close(cl.Message)
-                    continue
-                }
-
-                // Add the client to the room
-                room.Clients[cl.ID] = cl
-
-                cl.Message <- &Message{
-                    Type:     MESSAGE_TYPE_JOIN_SUCCESS,
-                    Content:  "Joined the room successfully",
-                    RoomID:   cl.RoomID,
-                }
-
-                if len(room.Clients) == 2 {
-                    // assign a puzzle to the clients
-                    hectocSeq := hectoc.Generate()
-
-                    room.Puzzle = hectocSeq
-
-                    if h.OnPuzzleCreated != nil {
-                        h.OnPuzzleCreated(cl.RoomID, hectocSeq)
-                    }
-
-                    for _, client := range room.Clients {
-                        client.Message <- &Message{
-                            Type:     MESSAGE_TYPE_PUZZLE_ASSIGN,
-                            Content:  hectocSeq,
-                            RoomID:   cl.RoomID,
-                            SenderID: client.ID,
-                        }
-                    }
-                }
-            } else {
-                // If the room doesn't exist, create it and add the client
-                h.Rooms[cl.RoomID] = &Room{
-                    ID:      cl.RoomID,
-                    Clients: map[string]*Client{cl.ID: cl},
-                }
-
-                cl.Message <- &Message{
-                    Type:     MESSAGE_TYPE_ROOM_CREATED,
-                    Content:  "Room created successfully",
-                    RoomID:   cl.RoomID,
-                }
+            room, ok := h.Rooms[cl.RoomID]
+
+            // Create the room if it doesn't exist, or replace one that has
+            // just shut down
+            if !ok || !room.send(roomEvent{kind: ROOM_EVENT_JOIN, client: cl}) {
+                room = newRoom(h, cl.RoomID, cl.Settings)
+                h.Rooms[cl.RoomID] = room
+                h.rooms.Store(int64(len(h.Rooms)))
+
+                go room.run()
+
+                room.send(roomEvent{kind: ROOM_EVENT_JOIN, client: cl})
             }
 
         case cl := <-h.Unregister:
-            // Handle client unregistration
-            if room, ok := h.Rooms[cl.RoomID]; ok {
-                if _, ok := room.Clients[cl.ID]; ok {
-                    delete(room.Clients, cl.ID)
-                    
-                    // Notify the client that they have left the room
-                    cl.Message <- &Message{
-                        Type:     MESSAGE_TYPE_LEAVE_SUCCESS,
-                        Content:  "Left the room successfully",
-                        RoomID:   cl.RoomID, 
-                    }
-
-                    close(cl.Message)
-
-                    // If the room is empty, delete it
-                    if len(room.Clients) == 0 {
-                        // If the room is empty, delete it
-                        delete(h.Rooms, cl.RoomID)
-
-                        if h.OnRoomEmpty != nil {
-                            h.OnRoomEmpty(cl.RoomID)
-                        }
-
-                    } else {
-                       // Notify the remaining client that the other client has left
-                       for _, remainingClient := range room.Clients {
-                            remainingClient.Message <- &Message{
-                                Type:     MESSAGE_TYPE_OPPONENT_LEFT,
-                                Content:  "Your opponent left the room",
-                                RoomID:   cl.RoomID,
-                            }
-                        }
-                    }
-
-                }
-            }
+            h.route(cl.RoomID, roomEvent{kind: ROOM_EVENT_LEAVE, client: cl})
+
+        case ev := <-h.messages:
+            h.route(ev.client.RoomID, ev)
 
         case m := <-h.Broadcast:
-            // Broadcast messages to all clients in the room except the sender
-            if room, ok := h.Rooms[m.RoomID]; ok {
-                for _, cl := range room.Clients {
-                    // if cl.ID == m.SenderID {
-                    //     continue
-                    // }
-                    cl.Message <- m
-                }
-            }
+            h.route(m.RoomID, roomEvent{kind: ROOM_EVENT_MESSAGE, msg: m})
+
+        case room := <-h.emptied:
+            h.closeRoom(room)
         }
     }
 }
 
+// Metrics returns a snapshot of the hub's delivery counters
+func (h *Hub) Metrics() HubMetrics {
+    return HubMetrics{
+        Rooms:     int(h.rooms.Load()),
+        Delivered: h.delivered.Load(),
+        Dropped:   h.dropped.Load(),
+        Evicted:   h.evicted.Load(),
+        Busy:      h.busy.Load(),
+    }
+}
+
+// route hands an event to the room it belongs to, if the room still exists.
+// Client messages a busy room has no space for are dropped rather than
+// waited on.
+func (h *Hub) route(roomID string, ev roomEvent) {
+    room, ok := h.Rooms[roomID]
+
+    if !ok {
+        return
+    }
+
+    if ev.kind != ROOM_EVENT_MESSAGE || ev.client == nil {
+        room.send(ev)
+        return
+    }
+
+    if !room.offer(ev) {
+        h.busy.Add(1)
+    }
+}
+
+// closeRoom forgets a room that shut itself down, unless it was replaced
+// in the meantime
+func (h *Hub) closeRoom(room *Room) {
+    if h.Rooms[room.ID] != room {
+        return
+    }
+
+    delete(h.Rooms, room.ID)
+    h.rooms.Store(int64(len(h.Rooms)))
+}
@@ -0,0 +1,1031 @@
+package ws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eclairjit/hecto-clash-hf/game-server/internal/store"
+	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
+	"github.com/gorilla/websocket"
+)
+
+const testTimeout = 10 * time.Second
+
+// testSettings keep puzzles small so the pool fills quickly
+var testSettings = RoomSettings{
+	Spec: hectoc.Spec{
+		Digits:   4,
+		Target:   10,
+		Alphabet: "123456789",
+		Rules:    hectoc.OfficialRules,
+	},
+	MinDifficulty: 0,
+	MaxDifficulty: hectoc.MAX_DIFFICULTY,
+}
+
+type testHub struct {
+	*Hub
+	emptied chan string
+	endings chan *Result
+	started chan time.Time
+}
+
+func newTestHub(t *testing.T) *testHub {
+	t.Helper()
+
+	pool := hectoc.NewPool(hectoc.PoolConfig{Workers: 2, Buffer: 4}, testSettings.poolKey())
+	pool.Start()
+	t.Cleanup(pool.Stop)
+
+	th := &testHub{
+		emptied: make(chan string, 100),
+		endings: make(chan *Result, 100),
+		started: make(chan time.Time, 100),
+	}
+
+	th.Hub = NewHub(pool,
+		func(roomID string) {
+			th.emptied <- roomID
+		},
+		func(roomID string, puzzle *hectoc.Hectoc, startedAt time.Time) {
+			th.started <- startedAt
+		},
+		nil,
+		func(roomID string, result *Result) {
+			th.endings <- result
+		},
+	)
+
+	// Most tests do not care for the lobby's countdown
+	th.Countdown = 10 * time.Millisecond
+
+	go th.Run()
+
+	return th
+}
+
+func newTestClient(id, roomID string) *Client {
+	return &Client{
+		Message:  make(chan *Message, CLIENT_QUEUE_SIZE),
+		ID:       id,
+		RoomID:   roomID,
+		Settings: testSettings,
+	}
+}
+
+// expect reads the client's next message and checks its type
+func expect(t *testing.T, cl *Client, want MessageType) *Message {
+	t.Helper()
+
+	select {
+	case msg, ok := <-cl.Message:
+		if !ok {
+			t.Fatalf("client %s: channel closed, want %s", cl.ID, want)
+		}
+		if msg.Type != want {
+			t.Fatalf("client %s: got %s (%v), want %s", cl.ID, msg.Type, msg.Content, want)
+		}
+		return msg
+	case <-time.After(testTimeout):
+		t.Fatalf("client %s: timed out waiting for %s", cl.ID, want)
+	}
+
+	return nil
+}
+
+// expectClosed checks that the client's channel is closed once drained
+func expectClosed(t *testing.T, cl *Client) {
+	t.Helper()
+
+	select {
+	case msg, ok := <-cl.Message:
+		if ok {
+			t.Fatalf("client %s: got %s, want closed channel", cl.ID, msg.Type)
+		}
+	case <-time.After(testTimeout):
+		t.Fatalf("client %s: channel not closed", cl.ID)
+	}
+}
+
+// eventually waits for a condition to hold
+func eventually(t *testing.T, condition func() bool, what string) {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// expectEnding waits for the hub to report a result
+func (th *testHub) expectEnding(t *testing.T, winnerID, loserID int64, reason EndReason) *Result {
+	t.Helper()
+
+	select {
+	case result := <-th.endings:
+		if result.WinnerID != winnerID || result.LoserID != loserID || result.Reason != reason {
+			t.Fatalf("result %+v, want %d beating %d by %s", result, winnerID, loserID, reason)
+		}
+
+		return result
+	case <-time.After(testTimeout):
+		t.Fatal("OnEnding was never called")
+	}
+
+	return nil
+}
+
+func (th *testHub) submit(cl *Client, expression string) {
+	th.messages <- roomEvent{
+		kind:   ROOM_EVENT_MESSAGE,
+		client: cl,
+		msg:    &Message{Type: MESSAGE_TYPE_SUBMIT, Content: expression, RoomID: cl.RoomID},
+	}
+}
+
+func (th *testHub) ready(cl *Client) {
+	th.messages <- roomEvent{
+		kind:   ROOM_EVENT_MESSAGE,
+		client: cl,
+		msg:    &Message{Type: MESSAGE_TYPE_READY, RoomID: cl.RoomID, SenderID: cl.ID},
+	}
+}
+
+// readyUp readies both players of a full room and returns the countdown
+// they are sent
+func (th *testHub) readyUp(t *testing.T, a, b *Client) *Countdown {
+	t.Helper()
+
+	th.ready(a)
+	th.ready(b)
+
+	for _, cl := range []*Client{a, b} {
+		expect(t, cl, MESSAGE_TYPE_READY)
+		expect(t, cl, MESSAGE_TYPE_READY)
+	}
+
+	countdown := expect(t, a, MESSAGE_TYPE_COUNTDOWN).Details.(*Countdown)
+	expect(t, b, MESSAGE_TYPE_COUNTDOWN)
+
+	return countdown
+}
+
+// readyUpSecond readies b once a is ready, returning the countdown
+func (th *testHub) readyUpSecond(t *testing.T, a, b *Client) *Countdown {
+	t.Helper()
+
+	th.ready(b)
+	expect(t, a, MESSAGE_TYPE_READY)
+	expect(t, b, MESSAGE_TYPE_READY)
+
+	countdown := expect(t, a, MESSAGE_TYPE_COUNTDOWN).Details.(*Countdown)
+	expect(t, b, MESSAGE_TYPE_COUNTDOWN)
+
+	return countdown
+}
+
+// join seats two clients in a room, returning the second one's session
+func (th *testHub) join(t *testing.T, a, b *Client) Session {
+	t.Helper()
+
+	th.Register <- a
+	expect(t, a, MESSAGE_TYPE_ROOM_CREATED)
+
+	th.Register <- b
+	session := expect(t, b, MESSAGE_TYPE_JOIN_SUCCESS).Details.(Session)
+	expect(t, a, MESSAGE_TYPE_OPPONENT_JOINED)
+
+	return session
+}
+
+// startGame joins two clients to a room, readies them and returns the
+// puzzle they get
+func (th *testHub) startGame(t *testing.T, a, b *Client) *hectoc.Hectoc {
+	t.Helper()
+
+	th.join(t, a, b)
+	th.readyUp(t, a, b)
+
+	puzzle := expect(t, a, MESSAGE_TYPE_PUZZLE_ASSIGN).Content.(*hectoc.Hectoc)
+	expect(t, b, MESSAGE_TYPE_PUZZLE_ASSIGN)
+
+	return puzzle
+}
+
+func TestRoomLifecycle(t *testing.T) {
+	th := newTestHub(t)
+
+	a, b, c := newTestClient("1", "room"), newTestClient("2", "room"), newTestClient("3", "room")
+
+	th.Register <- a
+	expect(t, a, MESSAGE_TYPE_ROOM_CREATED)
+
+	// Leaving before the puzzle is dealt costs nothing
+	th.Unregister <- a
+	expect(t, a, MESSAGE_TYPE_LEAVE_SUCCESS)
+	expectClosed(t, a)
+
+	// Leaving twice is harmless
+	th.Unregister <- a
+
+	select {
+	case roomID := <-th.emptied:
+		if roomID != "room" {
+			t.Fatalf("emptied %q, want room", roomID)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("room was never closed")
+	}
+
+	// The room ID can be used again once the room is gone
+	a = newTestClient("1", "room")
+	th.startGame(t, a, b)
+
+	th.Register <- c
+	expect(t, c, MESSAGE_TYPE_ROOM_FULL)
+	expectClosed(t, c)
+
+	if len(th.endings) > 0 {
+		t.Fatal("a game ended before anyone played")
+	}
+}
+
+func TestLeavingMidGameForfeits(t *testing.T) {
+	th := newTestHub(t)
+
+	a, b := newTestClient("1", "room"), newTestClient("2", "room")
+	th.startGame(t, a, b)
+
+	th.Unregister <- b
+	expect(t, b, MESSAGE_TYPE_LEAVE_SUCCESS)
+	expectClosed(t, b)
+
+	expect(t, a, MESSAGE_TYPE_FORFEIT)
+	expectClosed(t, a)
+
+	th.expectEnding(t, 1, 2, END_REASON_FORFEIT)
+
+	select {
+	case <-th.emptied:
+	case <-time.After(testTimeout):
+		t.Fatal("room was never closed")
+	}
+}
+
+func TestCorrectSubmissionEndsGame(t *testing.T) {
+	th := newTestHub(t)
+
+	a, b := newTestClient("1", "room"), newTestClient("2", "room")
+	puzzle := th.startGame(t, a, b)
+
+	th.submit(b, "1+")
+	expect(t, b, MESSAGE_TYPE_WRONG_SUBMISSION)
+
+	th.submit(a, puzzle.Solutions[0])
+	expect(t, a, MESSAGE_TYPE_CORRECT_SUBMISSION)
+	expect(t, b, MESSAGE_TYPE_END)
+	expectClosed(t, a)
+	expectClosed(t, b)
+
+	if result := th.expectEnding(t, 1, 2, END_REASON_SOLVED); !result.Rated {
+		t.Fatal("rated game reported as unrated")
+	}
+
+	select {
+	case ending := <-th.endings:
+		t.Fatalf("OnEnding called twice, again with %+v", ending)
+	case <-th.emptied:
+	case <-time.After(testTimeout):
+		t.Fatal("room was never closed")
+	}
+}
+
+func TestRepeatedSubmissionIsReported(t *testing.T) {
+	th := newTestHub(t)
+
+	a, b := newTestClient("1", "room"), newTestClient("2", "room")
+	puzzle := th.startGame(t, a, b)
+
+	// The digits summed never reach the target
+	digits := strings.Split(puzzle.Problem, "")
+	sum := strings.Join(digits, "+")
+	regrouped := "(" + digits[0] + "+" + digits[1] + ")+" + strings.Join(digits[2:], "+")
+
+	th.submit(a, sum)
+	if msg := expect(t, a, MESSAGE_TYPE_WRONG_SUBMISSION); strings.Contains(msg.Content.(string), "already") {
+		t.Fatalf("first submission reported as a repeat: %v", msg.Content)
+	}
+
+	th.submit(a, regrouped)
+	if msg := expect(t, a, MESSAGE_TYPE_WRONG_SUBMISSION); !strings.Contains(msg.Content.(string), "already") {
+		t.Fatalf("equivalent submission not reported as a repeat: %v", msg.Content)
+	}
+
+	// Repeats are per player
+	th.submit(b, sum)
+	if msg := expect(t, b, MESSAGE_TYPE_WRONG_SUBMISSION); strings.Contains(msg.Content.(string), "already") {
+		t.Fatalf("opponent's submission reported as a repeat: %v", msg.Content)
+	}
+}
+
+func TestPracticeGamesAreRecordedUnrated(t *testing.T) {
+	th := newTestHub(t)
+
+	a, b := newTestClient("1", "room"), newTestClient("2", "room")
+	a.Settings.Practice = true
+	puzzle := th.startGame(t, a, b)
+
+	th.submit(a, puzzle.Solutions[0])
+	expect(t, a, MESSAGE_TYPE_CORRECT_SUBMISSION)
+	expect(t, b, MESSAGE_TYPE_END)
+
+	if result := th.expectEnding(t, 1, 2, END_REASON_SOLVED); result.Rated {
+		t.Fatal("practice game reported as rated")
+	}
+}
+
+func TestHintPenaltyHoldsBackSubmissions(t *testing.T) {
+	th := newTestHub(t)
+
+	a, b := newTestClient("1", "room"), newTestClient("2", "room")
+	a.Settings.Practice = true
+	a.Settings.HintPenalty = 1
+	puzzle := th.startGame(t, a, b)
+
+	th.messages <- roomEvent{
+		kind:   ROOM_EVENT_MESSAGE,
+		client: a,
+		msg:    &Message{Type: MESSAGE_TYPE_HINT_REQUEST, Content: HintRequest{Level: 1}, RoomID: a.RoomID},
+	}
+	expect(t, a, MESSAGE_TYPE_HINT)
+
+	th.submit(a, puzzle.Solutions[0])
+	if msg := expect(t, a, MESSAGE_TYPE_WRONG_SUBMISSION); !strings.Contains(msg.Content.(string), "penalty") {
+		t.Fatalf("submission during the penalty answered with %v", msg.Content)
+	}
+
+	time.Sleep(time.Second)
+
+	th.submit(a, puzzle.Solutions[0])
+	expect(t, a, MESSAGE_TYPE_CORRECT_SUBMISSION)
+	expect(t, b, MESSAGE_TYPE_END)
+}
+
+func TestTimeUpIsADraw(t *testing.T) {
+	th := newTestHub(t)
+
+	a, b := newTestClient("1", "room"), newTestClient("2", "room")
+	a.Settings.TimeLimit = 1
+
+	th.join(t, a, b)
+	th.readyUp(t, a, b)
+
+	clock := expect(t, a, MESSAGE_TYPE_PUZZLE_ASSIGN).Details.(*Clock)
+	expect(t, b, MESSAGE_TYPE_PUZZLE_ASSIGN)
+
+	if clock.TimeLimitMs != 1000 {
+		t.Fatalf("time limit %dms, want 1000ms", clock.TimeLimitMs)
+	}
+
+	th.submit(b, "1+2+3+4*0")
+	expect(t, b, MESSAGE_TYPE_WRONG_SUBMISSION)
+
+	expect(t, a, MESSAGE_TYPE_TIME_UP)
+	expect(t, b, MESSAGE_TYPE_TIME_UP)
+	expectClosed(t, a)
+	expectClosed(t, b)
+
+	select {
+	case result := <-th.endings:
+		if !result.Draw || result.Reason != END_REASON_TIME_UP {
+			t.Fatalf("result %+v, want a draw on time", result)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("OnEnding was never called")
+	}
+}
+
+func TestPuzzleIsRevealedAtTheScheduledInstant(t *testing.T) {
+	th := newTestHub(t)
+	th.Countdown = 100 * time.Millisecond
+
+	a, b := newTestClient("1", "room"), newTestClient("2", "room")
+	th.join(t, a, b)
+
+	// Nothing is revealed, and nothing can be submitted, until both are ready
+	th.ready(a)
+	expect(t, a, MESSAGE_TYPE_READY)
+	expect(t, b, MESSAGE_TYPE_READY)
+
+	th.submit(a, "1+2+3+4")
+	expect(t, a, MESSAGE_TYPE_ERROR)
+
+	countdown := th.readyUpSecond(t, a, b)
+
+	if d := countdown.StartsAt - countdown.ServerTime; d != 100 || countdown.DurationMs != 100 {
+		t.Fatalf("countdown %+v, want the puzzle 100ms after the server time", countdown)
+	}
+
+	// The puzzle travels with the countdown, so both hold it before it
+	// is revealed
+	if countdown.Puzzle == nil {
+		t.Fatal("countdown came without the puzzle")
+	}
+
+	puzzle := expect(t, a, MESSAGE_TYPE_PUZZLE_ASSIGN).Content.(*hectoc.Hectoc)
+	expect(t, b, MESSAGE_TYPE_PUZZLE_ASSIGN)
+
+	if puzzle != countdown.Puzzle {
+		t.Fatalf("assigned %s, but counted down to %s", puzzle.Problem, countdown.Puzzle.Problem)
+	}
+
+	if now := time.Now().UnixMilli(); now < countdown.StartsAt {
+		t.Fatalf("puzzle revealed at %d, before the countdown ended at %d", now, countdown.StartsAt)
+	}
+
+	select {
+	case startedAt := <-th.started:
+		if startedAt.UnixMilli() != countdown.StartsAt {
+			t.Fatalf("game started at %d, want %d", startedAt.UnixMilli(), countdown.StartsAt)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("OnPuzzleCreated was never called")
+	}
+}
+
+func TestLobbyTimesOut(t *testing.T) {
+	th := newTestHub(t)
+	th.LobbyTimeout = 100 * time.Millisecond
+
+	a, b := newTestClient("1", "room"), newTestClient("2", "room")
+	th.join(t, a, b)
+
+	// b never gets ready
+	th.ready(a)
+	expect(t, a, MESSAGE_TYPE_READY)
+	expect(t, b, MESSAGE_TYPE_READY)
+
+	expect(t, a, MESSAGE_TYPE_LOBBY_TIMEOUT)
+	expect(t, b, MESSAGE_TYPE_LOBBY_TIMEOUT)
+	expectClosed(t, a)
+	expectClosed(t, b)
+
+	select {
+	case ending := <-th.endings:
+		t.Fatalf("game that never started ended with %+v", ending)
+	case <-th.emptied:
+	case <-time.After(testTimeout):
+		t.Fatal("room was never closed")
+	}
+}
+
+func TestDroppingOutCancelsTheCountdown(t *testing.T) {
+	th := newTestHub(t)
+	th.Countdown = 100 * time.Millisecond
+
+	a, b := newTestClient("1", "room"), newTestClient("2", "room")
+	session := th.join(t, a, b)
+	th.readyUp(t, a, b)
+
+	th.lose(b)
+	expect(t, a, MESSAGE_TYPE_CONNECTION_LOST)
+
+	time.Sleep(2 * th.Countdown)
+
+	select {
+	case msg := <-a.Message:
+		t.Fatalf("got %s after the countdown was called off", msg.Type)
+	default:
+	}
+
+	back := newTestClient("2", "room")
+	back.ResumeToken = session.ResumeToken
+	th.Register <- back
+
+	if resume := expect(t, back, MESSAGE_TYPE_RESUMED).Details.(*Resume); resume.Puzzle != nil {
+		t.Fatal("puzzle revealed to a player who resumed in the lobby")
+	}
+	expect(t, a, MESSAGE_TYPE_OPPONENT_RESUMED)
+
+	// a is still ready; only the player who dropped out readies again
+	th.readyUpSecond(t, a, back)
+
+	expect(t, a, MESSAGE_TYPE_PUZZLE_ASSIGN)
+	expect(t, back, MESSAGE_TYPE_PUZZLE_ASSIGN)
+}
+
+func TestMessagesOutsideTheRoomAreIgnored(t *testing.T) {
+	th := newTestHub(t)
+
+	a, b := newTestClient("1", "room"), newTestClient("2", "room")
+	th.startGame(t, a, b)
+
+	// A client that never joined cannot submit, even with a matching ID
+	th.submit(newTestClient("1", "room"), "1+2+3+4")
+
+	th.submit(a, "not an expression")
+	expect(t, a, MESSAGE_TYPE_WRONG_SUBMISSION)
+}
+
+// TestStalledClientsDoNotBlockTheHub fills the queues of two players who
+// stopped reading and checks that other games carry on
+func TestStalledClientsDoNotBlockTheHub(t *testing.T) {
+	th := newTestHub(t)
+
+	a, b := newTestClient("1", "stalled"), newTestClient("2", "stalled")
+	th.startGame(t, a, b)
+
+	const sent = CLIENT_QUEUE_SIZE + 8
+
+	for i := 0; i < sent; i++ {
+		th.Broadcast <- &Message{Type: "chat", Content: i, RoomID: "stalled"}
+	}
+
+	c, d := newTestClient("3", "other"), newTestClient("4", "other")
+	puzzle := th.startGame(t, c, d)
+
+	th.submit(c, puzzle.Solutions[0])
+	expect(t, c, MESSAGE_TYPE_CORRECT_SUBMISSION)
+	expect(t, d, MESSAGE_TYPE_END)
+
+	// The stalled room may still be working through its inbox
+	eventually(t, func() bool { return th.Metrics().Dropped == 2*8 }, "16 dropped messages")
+
+	if got := len(a.Message); got != CLIENT_QUEUE_SIZE {
+		t.Fatalf("queue holds %d messages, want %d", got, CLIENT_QUEUE_SIZE)
+	}
+}
+
+// TestBusyRoomDoesNotBlockTheHub floods one room with submissions while the
+// store hangs on them and checks that other games carry on
+func TestBusyRoomDoesNotBlockTheHub(t *testing.T) {
+	th := newTestHub(t)
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	th.OnSubmission = func(roomID string, submission *store.SubmissionStruct) {
+		if roomID == "flooded" {
+			<-release
+		}
+	}
+
+	a, b := newTestClient("1", "flooded"), newTestClient("2", "flooded")
+	puzzle := th.startGame(t, a, b)
+
+	// Keep a reading, so the flood is held up by the store and not by a
+	// full client queue
+	go func() {
+		for range a.Message {
+		}
+	}()
+
+	// The whole puzzle as one number is a wrong answer that still reaches
+	// the store
+	const sent = CALLBACK_QUEUE_SIZE + ROOM_INBOX_SIZE + 64
+
+	for i := 0; i < sent; i++ {
+		th.submit(a, puzzle.Problem)
+	}
+
+	eventually(t, func() bool { return th.Metrics().Busy > 0 }, "messages turned away from the busy room")
+
+	c, d := newTestClient("3", "other"), newTestClient("4", "other")
+	other := th.startGame(t, c, d)
+
+	th.submit(c, other.Solutions[0])
+	expect(t, c, MESSAGE_TYPE_CORRECT_SUBMISSION)
+	expect(t, d, MESSAGE_TYPE_END)
+
+	select {
+	case ending := <-th.endings:
+		if ending.WinnerID != 3 || ending.Reason != END_REASON_SOLVED {
+			t.Fatalf("got ending %+v, want player 3 to win by solving", ending)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("OnEnding was never called")
+	}
+}
+
+func TestSlowClientIsEvicted(t *testing.T) {
+	th := newTestHub(t)
+	th.SlowClientTimeout = 0
+
+	a, b := newTestClient("1", "room"), newTestClient("2", "room")
+	th.startGame(t, a, b)
+
+	done := make(chan struct{})
+
+	// b keeps up; a never reads again
+	go func() {
+		defer close(done)
+
+		for msg := range b.Message {
+			if msg.Type == MESSAGE_TYPE_FORFEIT {
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < CLIENT_QUEUE_SIZE; i++ {
+		th.Broadcast <- &Message{Type: "chat", Content: i, RoomID: "room"}
+	}
+
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("opponent was never credited with the win")
+	}
+
+	for range a.Message {
+	}
+
+	if evicted := th.Metrics().Evicted; evicted != 1 {
+		t.Fatalf("evicted %d clients, want 1", evicted)
+	}
+
+	th.expectEnding(t, 2, 1, END_REASON_FORFEIT)
+}
+
+func (th *testHub) lose(cl *Client) {
+	th.messages <- roomEvent{kind: ROOM_EVENT_LOST, client: cl}
+}
+
+func TestResumeAfterLostConnection(t *testing.T) {
+	th := newTestHub(t)
+
+	a, b := newTestClient("1", "room"), newTestClient("2", "room")
+
+	session := th.join(t, a, b)
+	th.readyUp(t, a, b)
+
+	puzzle := expect(t, a, MESSAGE_TYPE_PUZZLE_ASSIGN).Content.(*hectoc.Hectoc)
+	expect(t, b, MESSAGE_TYPE_PUZZLE_ASSIGN)
+
+	th.lose(b)
+	expectClosed(t, b)
+	expect(t, a, MESSAGE_TYPE_CONNECTION_LOST)
+
+	// The seat is held: nobody else can take it
+	c := newTestClient("3", "room")
+	th.Register <- c
+	expect(t, c, MESSAGE_TYPE_ROOM_FULL)
+
+	th.Broadcast <- &Message{Type: "chat", Content: "still there?", RoomID: "room"}
+	expect(t, a, "chat")
+
+	// Rejoining without the token is refused
+	impostor := newTestClient("2", "room")
+	th.Register <- impostor
+	expect(t, impostor, MESSAGE_TYPE_ERROR)
+
+	back := newTestClient("2", "room")
+	back.ResumeToken = session.ResumeToken
+	th.Register <- back
+
+	resume := expect(t, back, MESSAGE_TYPE_RESUMED).Details.(*Resume)
+	expect(t, a, MESSAGE_TYPE_OPPONENT_RESUMED)
+
+	if resume.Puzzle != puzzle {
+		t.Fatalf("resumed with puzzle %v, want %v", resume.Puzzle, puzzle)
+	}
+
+	if len(resume.Missed) != 1 || resume.Missed[0].Content != "still there?" {
+		t.Fatalf("missed %v, want the chat message", resume.Missed)
+	}
+
+	if resume.ResumeToken == session.ResumeToken {
+		t.Fatal("resume token was not replaced")
+	}
+
+	th.submit(back, puzzle.Solutions[0])
+	expect(t, back, MESSAGE_TYPE_CORRECT_SUBMISSION)
+	expect(t, a, MESSAGE_TYPE_END)
+}
+
+func TestNotComingBackForfeits(t *testing.T) {
+	th := newTestHub(t)
+	th.ResumeGrace = 50 * time.Millisecond
+
+	a, b := newTestClient("1", "room"), newTestClient("2", "room")
+	th.startGame(t, a, b)
+
+	th.lose(b)
+	expect(t, a, MESSAGE_TYPE_CONNECTION_LOST)
+	expect(t, a, MESSAGE_TYPE_FORFEIT)
+	expectClosed(t, a)
+
+	th.expectEnding(t, 1, 2, END_REASON_FORFEIT)
+}
+
+func TestSeatIsReleasedAfterGrace(t *testing.T) {
+	th := newTestHub(t)
+	th.ResumeGrace = 50 * time.Millisecond
+
+	a, b := newTestClient("1", "room"), newTestClient("2", "room")
+
+	th.Register <- a
+	expect(t, a, MESSAGE_TYPE_ROOM_CREATED)
+
+	// Without a puzzle there is no game to forfeit
+	th.lose(a)
+	expectClosed(t, a)
+
+	select {
+	case <-th.emptied:
+	case <-time.After(testTimeout):
+		t.Fatal("room was never closed")
+	}
+
+	th.Register <- b
+	expect(t, b, MESSAGE_TYPE_ROOM_CREATED)
+
+	if len(th.endings) > 0 {
+		t.Fatal("a game ended before anyone played")
+	}
+}
+
+func TestOpponentAwayStillLoses(t *testing.T) {
+	th := newTestHub(t)
+
+	a, b := newTestClient("1", "room"), newTestClient("2", "room")
+	puzzle := th.startGame(t, a, b)
+
+	th.lose(b)
+	expect(t, a, MESSAGE_TYPE_CONNECTION_LOST)
+
+	th.submit(a, puzzle.Solutions[0])
+	expect(t, a, MESSAGE_TYPE_CORRECT_SUBMISSION)
+
+	th.expectEnding(t, 1, 2, END_REASON_SOLVED)
+
+	select {
+	case <-th.emptied:
+	case <-time.After(testTimeout):
+		t.Fatal("room was never closed")
+	}
+}
+
+// TestConcurrentRooms plays many games at once, with submissions and leaves
+// racing each other. Run with -race.
+func TestConcurrentRooms(t *testing.T) {
+	th := newTestHub(t)
+
+	const rooms = 20
+
+	var wg sync.WaitGroup
+	var received atomic.Int64
+
+	// drain reads a client's messages until its channel is closed
+	drain := func(cl *Client, puzzles chan<- *hectoc.Hectoc) {
+		defer wg.Done()
+
+		for msg := range cl.Message {
+			received.Add(1)
+
+			if msg.Type == MESSAGE_TYPE_PUZZLE_ASSIGN && puzzles != nil {
+				puzzles <- msg.Content.(*hectoc.Hectoc)
+			}
+		}
+	}
+
+	for i := 0; i < rooms; i++ {
+		roomID := fmt.Sprintf("room-%d", i)
+		a := newTestClient(fmt.Sprint(2*i+1), roomID)
+		b := newTestClient(fmt.Sprint(2*i+2), roomID)
+		puzzles := make(chan *hectoc.Hectoc, 2)
+
+		wg.Add(3)
+		go drain(a, puzzles)
+		go drain(b, nil)
+
+		go func(i int) {
+			defer wg.Done()
+
+			th.Register <- a
+			th.Register <- b
+			th.ready(a)
+			th.ready(b)
+
+			var puzzle *hectoc.Hectoc
+
+			select {
+			case puzzle = <-puzzles:
+			case <-time.After(testTimeout):
+				t.Errorf("%s: no puzzle", roomID)
+				return
+			}
+
+			var players sync.WaitGroup
+			players.Add(2)
+
+			go func() {
+				defer players.Done()
+
+				for j := 0; j < 5; j++ {
+					th.submit(b, "1+2+3+4*0")
+				}
+			}()
+
+			go func() {
+				defer players.Done()
+
+				// Every other room is forfeited instead of solved
+				if i%2 == 0 {
+					th.submit(a, puzzle.Solutions[0])
+				}
+
+				th.Unregister <- a
+				th.Unregister <- b
+			}()
+
+			players.Wait()
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i := 0; i < rooms; i++ {
+		select {
+		case <-th.emptied:
+		case <-time.After(testTimeout):
+			t.Fatalf("only %d of %d rooms closed", i, rooms)
+		}
+	}
+
+	// Every game ends once, solved or forfeited
+	if n := len(th.endings); n != rooms {
+		t.Fatalf("%d games ended, want %d", n, rooms)
+	}
+
+	if received.Load() == 0 {
+		t.Fatal("no messages delivered")
+	}
+}
+
+// serve runs a server that joins every connection to one room of the hub
+func serve(t *testing.T, th *testHub, roomID string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+
+		cl := newTestClient(r.URL.Query().Get("userId"), roomID)
+		cl.Conn = conn
+
+		th.Register <- cl
+
+		go cl.WriteMessage(th.Hub)
+		cl.ReadMessage(th.Hub)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func dial(t *testing.T, server *httptest.Server, userID string) *websocket.Conn {
+	t.Helper()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/?userId=" + userID
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// readyUp readies two players over their connections and waits for the
+// puzzle, returning it
+func readyUp(t *testing.T, a, b *websocket.Conn) map[string]any {
+	t.Helper()
+
+	read(t, a, MESSAGE_TYPE_OPPONENT_JOINED)
+
+	a.WriteJSON(&Message{Type: MESSAGE_TYPE_READY})
+	b.WriteJSON(&Message{Type: MESSAGE_TYPE_READY})
+
+	var puzzle map[string]any
+
+	for _, conn := range []*websocket.Conn{a, b} {
+		read(t, conn, MESSAGE_TYPE_READY)
+		read(t, conn, MESSAGE_TYPE_READY)
+		read(t, conn, MESSAGE_TYPE_COUNTDOWN)
+		puzzle = read(t, conn, MESSAGE_TYPE_PUZZLE_ASSIGN)["content"].(map[string]any)
+	}
+
+	return puzzle
+}
+
+// read reads the next message from the server and checks its type
+func read(t *testing.T, conn *websocket.Conn, want MessageType) map[string]any {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(testTimeout))
+
+	var msg map[string]any
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("reading %s: %v", want, err)
+	}
+
+	if msg["type"] != string(want) {
+		t.Fatalf("got %v (%v), want %s", msg["type"], msg["content"], want)
+	}
+
+	return msg
+}
+
+// TestWebSocketGame plays a game over real connections, so the clients'
+// read and write loops run against the hub. Run with -race.
+func TestWebSocketGame(t *testing.T) {
+	th := newTestHub(t)
+	server := serve(t, th, "room")
+
+	a := dial(t, server, "1")
+	read(t, a, MESSAGE_TYPE_ROOM_CREATED)
+
+	b := dial(t, server, "2")
+	read(t, b, MESSAGE_TYPE_JOIN_SUCCESS)
+
+	puzzle := readyUp(t, a, b)
+
+	solution := puzzle["solutions"].([]any)[0].(string)
+
+	// A spoofed room ID is ignored in favour of the connection's own room
+	b.WriteJSON(&Message{Type: MESSAGE_TYPE_SUBMIT, Content: "1+2+3+4*0", RoomID: "elsewhere"})
+	read(t, b, MESSAGE_TYPE_WRONG_SUBMISSION)
+
+	a.WriteJSON(&Message{Type: MESSAGE_TYPE_SUBMIT, Content: solution})
+	read(t, a, MESSAGE_TYPE_CORRECT_SUBMISSION)
+	read(t, b, MESSAGE_TYPE_END)
+
+	th.expectEnding(t, 1, 2, END_REASON_SOLVED)
+}
+
+// TestSilentConnectionIsLost checks that a player who stops answering pings
+// is dropped, while one who keeps reading stays connected
+func TestSilentConnectionIsLost(t *testing.T) {
+	th := newTestHub(t)
+	th.Heartbeat = Heartbeat{
+		PongWait:       300 * time.Millisecond,
+		PingPeriod:     100 * time.Millisecond,
+		WriteWait:      time.Second,
+		MaxMessageSize: DefaultHeartbeat.MaxMessageSize,
+	}
+
+	server := serve(t, th, "room")
+
+	a := dial(t, server, "1")
+	read(t, a, MESSAGE_TYPE_ROOM_CREATED)
+
+	b := dial(t, server, "2")
+	read(t, b, MESSAGE_TYPE_JOIN_SUCCESS)
+
+	readyUp(t, a, b)
+
+	// a keeps reading, which answers the server's pings; b never reads
+	// again, so its pongs stop
+	lost := read(t, a, MESSAGE_TYPE_CONNECTION_LOST)
+
+	if lost["senderId"] != "2" {
+		t.Fatalf("lost connection of %v, want 2", lost["senderId"])
+	}
+
+	a.WriteJSON(&Message{Type: MESSAGE_TYPE_SUBMIT, Content: "1+2+3+4*0"})
+	read(t, a, MESSAGE_TYPE_WRONG_SUBMISSION)
+}
+
+func TestOversizedMessageDropsConnection(t *testing.T) {
+	th := newTestHub(t)
+	th.Heartbeat.MaxMessageSize = 64
+
+	server := serve(t, th, "room")
+
+	a := dial(t, server, "1")
+	read(t, a, MESSAGE_TYPE_ROOM_CREATED)
+
+	b := dial(t, server, "2")
+	read(t, b, MESSAGE_TYPE_JOIN_SUCCESS)
+
+	readyUp(t, a, b)
+
+	b.WriteJSON(&Message{Type: MESSAGE_TYPE_SUBMIT, Content: strings.Repeat("1+", 64)})
+	read(t, a, MESSAGE_TYPE_CONNECTION_LOST)
+}
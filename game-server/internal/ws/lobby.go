@@ -0,0 +1,135 @@
+package ws
+
+import (
+	"time"
+
+	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
+)
+
+// DEFAULT_COUNTDOWN is how long after both players are ready the puzzle is
+// revealed, unless the hub says otherwise
+const DEFAULT_COUNTDOWN = 3 * time.Second
+
+// DEFAULT_LOBBY_TIMEOUT is how long a full room waits for both players to be
+// ready, unless the hub says otherwise
+const DEFAULT_LOBBY_TIMEOUT = 2 * time.Minute
+
+// Countdown is sent with countdown. It carries the puzzle, which clients
+// keep hidden until StartsAt: sending it ahead means a player closer to the
+// server does not see it first. ServerTime lets clients correct for the
+// offset of their own clock. Times are Unix milliseconds.
+type Countdown struct {
+	ServerTime int64          `json:"serverTime"`
+	StartsAt   int64          `json:"startsAt"`
+	DurationMs int64          `json:"durationMs"`
+	Puzzle     *hectoc.Hectoc `json:"puzzle"`
+}
+
+// openLobby gives the players of a full room LobbyTimeout to get ready
+func (r *Room) openLobby() {
+	if r.hub.LobbyTimeout <= 0 || r.lobby != nil {
+		return
+	}
+
+	r.lobby = time.AfterFunc(r.hub.LobbyTimeout, func() {
+		r.send(roomEvent{kind: ROOM_EVENT_LOBBY_TIMEOUT})
+	})
+}
+
+// lobbyTimeout calls the game off if it has not started yet. Nobody played,
+// so nobody wins or loses.
+func (r *Room) lobbyTimeout() {
+	if r.Puzzle != nil || r.seated() == 0 {
+		return
+	}
+
+	r.cancelCountdown()
+
+	r.broadcast(&Message{
+		Type:    MESSAGE_TYPE_LOBBY_TIMEOUT,
+		Content: "The game was called off because not every player got ready in time",
+		RoomID:  r.ID,
+	})
+
+	r.finish()
+}
+
+// deal keeps the puzzle for the game until both players are ready
+func (r *Room) deal(puzzle *hectoc.Hectoc) {
+	r.dealt = puzzle
+	r.startCountdown()
+}
+
+// markReady records that a player is ready and tells both players. The
+// countdown starts once both are and the puzzle is dealt.
+func (r *Room) markReady(cl *Client) {
+	if r.Puzzle != nil || r.ready[cl.ID] {
+		return
+	}
+
+	r.ready[cl.ID] = true
+
+	r.broadcast(&Message{
+		Type:     MESSAGE_TYPE_READY,
+		Content:  "Ready",
+		RoomID:   r.ID,
+		SenderID: cl.ID,
+	})
+
+	r.startCountdown()
+}
+
+// startCountdown schedules the reveal if both players are connected and
+// ready, the puzzle is dealt and no countdown is running
+func (r *Room) startCountdown() {
+	if r.dealt == nil || r.Puzzle != nil || r.countdown != nil || len(r.Clients) < 2 {
+		return
+	}
+
+	for id := range r.Clients {
+		if !r.ready[id] {
+			return
+		}
+	}
+
+	now := time.Now()
+	startsAt := now.Add(r.hub.Countdown)
+	r.startsAt = startsAt
+
+	r.countdown = time.AfterFunc(r.hub.Countdown, func() {
+		r.send(roomEvent{kind: ROOM_EVENT_REVEAL, at: startsAt})
+	})
+
+	r.broadcast(&Message{
+		Type:    MESSAGE_TYPE_COUNTDOWN,
+		Content: "Get ready!",
+		RoomID:  r.ID,
+		Details: &Countdown{
+			ServerTime: now.UnixMilli(),
+			StartsAt:   startsAt.UnixMilli(),
+			DurationMs: r.hub.Countdown.Milliseconds(),
+			Puzzle:     r.dealt,
+		},
+	})
+}
+
+// cancelCountdown calls off a countdown when a player drops out of the
+// lobby; both have to be ready again
+func (r *Room) cancelCountdown() {
+	if r.countdown == nil || r.Puzzle != nil {
+		return
+	}
+
+	r.countdown.Stop()
+	r.countdown = nil
+}
+
+// reveal opens the game at the instant the countdown named, unless that
+// countdown was called off
+func (r *Room) reveal(at time.Time) {
+	if r.countdown == nil || r.Puzzle != nil || !at.Equal(r.startsAt) {
+		return
+	}
+
+	r.assignPuzzle(r.dealt, r.startsAt)
+}
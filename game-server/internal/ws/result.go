@@ -0,0 +1,144 @@
+package ws
+
+import (
+	"strconv"
+
+	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
+)
+
+// Clock is sent with puzzle_assign and resumed: how long the game may last
+type Clock struct {
+	// TimeLimitMs is zero if the game has no time limit
+	TimeLimitMs int64 `json:"timeLimitMs"`
+}
+
+// EndReason says how a game finished
+type EndReason string
+
+const (
+	END_REASON_SOLVED  EndReason = "solved"
+	END_REASON_FORFEIT EndReason = "forfeit"
+	END_REASON_TIME_UP EndReason = "time_up"
+)
+
+// Result is the outcome of a game, handed to the hub's OnEnding. In a draw
+// WinnerID and LoserID are simply the two players.
+type Result struct {
+	WinnerID int64
+	LoserID  int64
+	Draw     bool
+	Reason   EndReason
+	// Rated is false for practice games, which leave ratings alone
+	Rated bool
+	// Submission is the winning answer; empty for a forfeit or a draw
+	Submission string
+}
+
+// forfeit ends a game in progress in favour of the player who stayed. It
+// reports false, leaving the room as it is, if no puzzle was dealt yet or
+// nobody is left to win.
+func (r *Room) forfeit(loser *Client) bool {
+	if r.Puzzle == nil {
+		return false
+	}
+
+	winnerID := ""
+
+	for id := range r.Clients {
+		if id != loser.ID {
+			winnerID = id
+		}
+	}
+
+	for id := range r.away {
+		if id != loser.ID {
+			winnerID = id
+		}
+	}
+
+	if winnerID == "" {
+		return false
+	}
+
+	r.broadcast(&Message{
+		Type:     MESSAGE_TYPE_FORFEIT,
+		Content:  "Your opponent left the game. You win by forfeit!",
+		RoomID:   r.ID,
+		SenderID: loser.ID,
+	})
+
+	if r.hub.OnEnding != nil {
+		winner, werr := strconv.ParseInt(winnerID, 10, 64)
+		lost, lerr := strconv.ParseInt(loser.ID, 10, 64)
+
+		if werr == nil && lerr == nil {
+			r.ended(&Result{
+				WinnerID: winner,
+				LoserID:  lost,
+				Reason:   END_REASON_FORFEIT,
+				Rated:    !r.Settings.Practice,
+			})
+		}
+	}
+
+	r.finish()
+
+	return true
+}
+
+// timeUp draws the game if the puzzle whose clock ran out is still being
+// played
+func (r *Room) timeUp(puzzle *hectoc.Hectoc) {
+	if r.Puzzle != puzzle || r.seated() == 0 {
+		return
+	}
+
+	r.broadcast(&Message{
+		Type:    MESSAGE_TYPE_TIME_UP,
+		Content: "Time is up! The game is a draw.",
+		RoomID:  r.ID,
+	})
+
+	var playerIDs []int64
+
+	for id := range r.Clients {
+		if playerID, err := strconv.ParseInt(id, 10, 64); err == nil {
+			playerIDs = append(playerIDs, playerID)
+		}
+	}
+
+	for id := range r.away {
+		if playerID, err := strconv.ParseInt(id, 10, 64); err == nil {
+			playerIDs = append(playerIDs, playerID)
+		}
+	}
+
+	if r.hub.OnEnding != nil && len(playerIDs) == 2 {
+		r.ended(&Result{
+			WinnerID: playerIDs[0],
+			LoserID:  playerIDs[1],
+			Draw:     true,
+			Reason:   END_REASON_TIME_UP,
+			Rated:    !r.Settings.Practice,
+		})
+	}
+
+	r.finish()
+}
+
+// finish lets every player go once the game is over, so the room closes
+func (r *Room) finish() {
+	if r.clock != nil {
+		r.clock.Stop()
+	}
+
+	r.vacate()
+
+	for _, cl := range r.Clients {
+		r.remove(cl)
+		close(cl.Message)
+		delete(r.tokens, cl.ID)
+	}
+
+	r.closeIfEmpty()
+}
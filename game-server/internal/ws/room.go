@@ -0,0 +1,580 @@
+package ws
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/eclairjit/hecto-clash-hf/game-server/internal/store"
+	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
+)
+
+// ROOM_INBOX_SIZE is how many client messages may wait for a busy room;
+// further ones are dropped
+const ROOM_INBOX_SIZE = 16
+
+// CALLBACK_QUEUE_SIZE is how many store callbacks may wait for a room's
+// callback goroutine before the room itself waits for the store
+const CALLBACK_QUEUE_SIZE = 256
+
+type roomEventKind int
+
+// Things a room reacts to
+const (
+	ROOM_EVENT_JOIN roomEventKind = iota
+	ROOM_EVENT_LEAVE
+	ROOM_EVENT_LOST
+	ROOM_EVENT_EXPIRED
+	ROOM_EVENT_TIME_UP
+	ROOM_EVENT_REVEAL
+	ROOM_EVENT_LOBBY_TIMEOUT
+	ROOM_EVENT_MESSAGE
+	ROOM_EVENT_PUZZLE
+)
+
+// roomEvent is one entry of a room's inbox. A message without a client is a
+// broadcast from the server. Client messages go through the bounded inbox,
+// every other event through the control queue, which is never dropped.
+type roomEvent struct {
+	kind   roomEventKind
+	client *Client
+	msg    *Message
+	puzzle *hectoc.Hectoc
+	err    error
+	// at names the countdown a reveal belongs to
+	at time.Time
+}
+
+type Room struct {
+	ID       string             `json:"id"`
+	Clients  map[string]*Client `json:"clients"`
+	Puzzle   *hectoc.Hectoc     `json:"puzzle"`
+	Settings RoomSettings       `json:"settings"`
+
+	hub   *Hub
+	inbox chan roomEvent
+	done  chan struct{}
+
+	// control queues joins, departures, timers, puzzles and server
+	// broadcasts. It is only bounded by the clients and timers of the room,
+	// so queueing never blocks. Once closed is set nothing more is queued.
+	mu      sync.Mutex
+	control []roomEvent
+	closed  bool
+	wake    chan struct{}
+
+	// callbacks runs the hub's store callbacks for the room in order
+	callbacks chan func()
+
+	// away holds the seats of players whose connection dropped, tokens the
+	// resume token of every player
+	away      map[string]*seat
+	tokens    map[string]string
+	startedAt time.Time
+	// clock draws the game when the time limit runs out
+	clock *time.Timer
+
+	// The lobby: the puzzle waits in dealt until both players are ready,
+	// then countdown reveals it at startsAt. lobby calls the game off if
+	// that takes too long.
+	dealt     *hectoc.Hectoc
+	ready     map[string]bool
+	startsAt  time.Time
+	countdown *time.Timer
+	lobby     *time.Timer
+
+	// tried holds the canonical form of every answer each player has
+	// submitted, so a repeat is caught without waiting on the store
+	tried map[string]map[string]bool
+	// penalisedUntil holds, for every player who took a hint, when they may
+	// submit again
+	penalisedUntil map[string]time.Time
+}
+
+func newRoom(h *Hub, id string, settings RoomSettings) *Room {
+	return &Room{
+		ID:        id,
+		Clients:   make(map[string]*Client),
+		Settings:  settings,
+		hub:       h,
+		inbox:     make(chan roomEvent, ROOM_INBOX_SIZE),
+		done:      make(chan struct{}),
+		wake:      make(chan struct{}, 1),
+		callbacks: make(chan func(), CALLBACK_QUEUE_SIZE),
+		away:      make(map[string]*seat),
+		tokens:    make(map[string]string),
+		ready:     make(map[string]bool),
+		tried:     make(map[string]map[string]bool),
+
+		penalisedUntil: make(map[string]time.Time),
+	}
+}
+
+// send queues a control event for the room. It never blocks, and reports
+// false if the room has shut down.
+func (r *Room) send(ev roomEvent) bool {
+	r.mu.Lock()
+
+	if r.closed {
+		r.mu.Unlock()
+		return false
+	}
+
+	r.control = append(r.control, ev)
+	r.mu.Unlock()
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+		// The room has a wake-up coming already
+	}
+
+	return true
+}
+
+// offer queues a message for the room without blocking. It reports false if
+// the room is too busy to take it, or has shut down.
+func (r *Room) offer(ev roomEvent) bool {
+	select {
+	case <-r.done:
+		return false
+	default:
+	}
+
+	select {
+	case r.inbox <- ev:
+		return true
+	default:
+		return false
+	}
+}
+
+// nextControl takes the oldest control event off the queue
+func (r *Room) nextControl() (roomEvent, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.control) == 0 {
+		return roomEvent{}, false
+	}
+
+	ev := r.control[0]
+	r.control = r.control[1:]
+
+	return ev, true
+}
+
+// run processes the room's events one at a time, control events first,
+// until the room is empty and shuts itself down
+func (r *Room) run() {
+	defer close(r.done)
+
+	go r.runCallbacks()
+
+	for {
+		for !r.closed {
+			ev, ok := r.nextControl()
+
+			if !ok {
+				break
+			}
+
+			r.handle(ev)
+		}
+
+		if r.closed {
+			// Callbacks run in order, so the room is forgotten only
+			// after its game is recorded
+			if r.hub.OnRoomEmpty != nil {
+				r.record(func() {
+					r.hub.OnRoomEmpty(r.ID)
+				})
+			}
+
+			close(r.callbacks)
+
+			r.hub.emptied <- r
+			return
+		}
+
+		select {
+		case <-r.wake:
+		case ev := <-r.inbox:
+			r.handle(ev)
+		}
+	}
+}
+
+// handle acts on one event
+func (r *Room) handle(ev roomEvent) {
+	switch ev.kind {
+	case ROOM_EVENT_JOIN:
+		r.join(ev.client)
+
+	case ROOM_EVENT_LEAVE:
+		r.leave(ev.client)
+
+	case ROOM_EVENT_LOST:
+		r.connectionLost(ev.client)
+
+	case ROOM_EVENT_EXPIRED:
+		r.abandon(ev.client)
+
+	case ROOM_EVENT_TIME_UP:
+		r.timeUp(ev.puzzle)
+
+	case ROOM_EVENT_REVEAL:
+		r.reveal(ev.at)
+
+	case ROOM_EVENT_LOBBY_TIMEOUT:
+		r.lobbyTimeout()
+
+	case ROOM_EVENT_MESSAGE:
+		r.handleMessage(ev.client, ev.msg)
+
+	case ROOM_EVENT_PUZZLE:
+		r.puzzleReady(ev.puzzle, ev.err)
+	}
+
+	r.evictSlow()
+	r.closeIfEmpty()
+}
+
+func (r *Room) join(cl *Client) {
+	// A player coming back with their token takes their seat again
+	if r.canResume(cl) {
+		r.resume(cl)
+		return
+	}
+
+	// A player already seated needs their token to connect again
+	if _, ok := r.tokens[cl.ID]; ok {
+		r.deliver(cl, &Message{
+			Type:    MESSAGE_TYPE_ERROR,
+			Content: "You are already in this room",
+			RoomID:  r.ID,
+		})
+		close(cl.Message)
+		return
+	}
+
+	// Check if the room already has 2 players, counting those away
+	if r.seated() >= 2 {
+		// Notify the client that the room is full
+		r.deliver(cl, &Message{
+			Type:    MESSAGE_TYPE_ROOM_FULL,
+			Content: "Room is full",
+			RoomID:  r.ID,
+		})
+		close(cl.Message)
+		return
+	}
+
+	r.Clients[cl.ID] = cl
+	session := r.session(cl.ID)
+
+	if r.seated() == 1 {
+		r.deliver(cl, &Message{
+			Type:    MESSAGE_TYPE_ROOM_CREATED,
+			Content: "Room created successfully",
+			RoomID:  r.ID,
+			Details: session,
+		})
+		return
+	}
+
+	r.deliver(cl, &Message{
+		Type:    MESSAGE_TYPE_JOIN_SUCCESS,
+		Content: "Joined the room successfully",
+		RoomID:  r.ID,
+		Details: session,
+	})
+
+	for _, other := range r.Clients {
+		if other != cl {
+			r.deliver(other, &Message{
+				Type:     MESSAGE_TYPE_OPPONENT_JOINED,
+				Content:  "Your opponent joined the room",
+				RoomID:   r.ID,
+				SenderID: cl.ID,
+			})
+		}
+	}
+
+	r.openLobby()
+
+	// deal a puzzle for the game, waiting on the pool in the background if
+	// none is ready yet; it is revealed once both players are ready
+	if puzzle, ok := r.hub.Pool.TryGet(r.Settings.poolKey()); ok {
+		r.deal(puzzle)
+	} else {
+		go r.awaitPuzzle(r.Settings.poolKey())
+	}
+}
+
+func (r *Room) leave(cl *Client) {
+	if !r.remove(cl) {
+		return
+	}
+
+	delete(r.tokens, cl.ID)
+
+	// Notify the client that they have left the room
+	r.deliver(cl, &Message{
+		Type:    MESSAGE_TYPE_LEAVE_SUCCESS,
+		Content: "Left the room successfully",
+		RoomID:  r.ID,
+	})
+	close(cl.Message)
+
+	// Leaving a game in progress hands the opponent the win
+	if r.forfeit(cl) {
+		return
+	}
+
+	// Notify the remaining client that the other client has left
+	r.broadcast(&Message{
+		Type:    MESSAGE_TYPE_OPPONENT_LEFT,
+		Content: "Your opponent left the room",
+		RoomID:  r.ID,
+	})
+
+	r.closeIfEmpty()
+}
+
+// remove takes a client out of the room, reporting whether it was in it.
+// The caller closes the client's channel and then calls closeIfEmpty.
+func (r *Room) remove(cl *Client) bool {
+	if existing, ok := r.Clients[cl.ID]; !ok || existing != cl {
+		return false
+	}
+
+	delete(r.Clients, cl.ID)
+
+	// A player who drops out of the lobby has to be ready again
+	delete(r.ready, cl.ID)
+	r.cancelCountdown()
+
+	return true
+}
+
+// closeIfEmpty shuts the room down once no player is left, connected or
+// away, unless a client is already queued to join it
+func (r *Room) closeIfEmpty() {
+	if r.seated() > 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, ev := range r.control {
+		if ev.kind == ROOM_EVENT_JOIN {
+			return
+		}
+	}
+
+	r.closed = true
+}
+
+// handleMessage acts on a message from a client, or broadcasts one from
+// the server
+func (r *Room) handleMessage(cl *Client, msg *Message) {
+	if cl == nil {
+		r.broadcast(msg)
+		return
+	}
+
+	// Only clients in the room may act in it
+	if existing, ok := r.Clients[cl.ID]; !ok || existing != cl {
+		return
+	}
+
+	switch msg.Type {
+	case MESSAGE_TYPE_SUBMIT:
+		r.handleSubmission(cl, msg)
+
+	case MESSAGE_TYPE_HINT_REQUEST:
+		r.handleHintRequest(cl, msg)
+
+	case MESSAGE_TYPE_READY:
+		r.markReady(cl)
+
+	default:
+		r.broadcast(msg)
+	}
+}
+
+// deliver queues a message for a client without blocking the room. The
+// message is dropped if the client's queue is full, and a client that stays
+// above the high-water mark for longer than the hub's SlowClientTimeout is
+// marked for eviction.
+func (r *Room) deliver(cl *Client, msg *Message) {
+	select {
+	case cl.Message <- msg:
+		r.hub.delivered.Add(1)
+	default:
+		cl.dropped++
+		r.hub.dropped.Add(1)
+	}
+
+	switch {
+	case len(cl.Message) < CLIENT_HIGH_WATER:
+		cl.slowSince = time.Time{}
+	case cl.slowSince.IsZero():
+		cl.slowSince = time.Now()
+	case time.Since(cl.slowSince) > r.hub.SlowClientTimeout:
+		cl.slow = true
+	}
+}
+
+// evictSlow disconnects the clients marked slow. Closing a client's channel
+// stops its writer, which closes the connection.
+func (r *Room) evictSlow() {
+	for _, cl := range r.Clients {
+		if !cl.slow || !r.remove(cl) {
+			continue
+		}
+
+		log.Printf("disconnecting slow client %s from room %s after %d dropped messages", cl.ID, r.ID, cl.dropped)
+
+		r.hub.evicted.Add(1)
+		close(cl.Message)
+		delete(r.tokens, cl.ID)
+
+		if r.forfeit(cl) {
+			return
+		}
+
+		r.broadcast(&Message{
+			Type:    MESSAGE_TYPE_OPPONENT_LEFT,
+			Content: "Your opponent's connection was too slow",
+			RoomID:  r.ID,
+		})
+
+		r.closeIfEmpty()
+	}
+}
+
+// broadcast sends a message to every client in the room, and keeps it for
+// the players who are away
+func (r *Room) broadcast(msg *Message) {
+	for _, cl := range r.Clients {
+		r.deliver(cl, msg)
+	}
+
+	for _, s := range r.away {
+		s.hold(msg)
+	}
+}
+
+// puzzleReady deals a puzzle the pool delivered after a miss, if the room
+// still has both players and no puzzle
+func (r *Room) puzzleReady(puzzle *hectoc.Hectoc, err error) {
+	if r.seated() < 2 || r.dealt != nil {
+		return
+	}
+
+	if err != nil {
+		r.broadcast(&Message{
+			Type:    MESSAGE_TYPE_ERROR,
+			Content: "Failed to generate a puzzle for this room",
+			RoomID:  r.ID,
+		})
+		return
+	}
+
+	r.deal(puzzle)
+}
+
+// assignPuzzle opens the game: submissions count from now on and the clock
+// starts. Both players already hold the puzzle from the countdown, so
+// puzzle_assign only confirms it; a player who is away gets it on resuming.
+func (r *Room) assignPuzzle(puzzle *hectoc.Hectoc, startedAt time.Time) {
+	r.Puzzle = puzzle
+	r.startedAt = startedAt
+
+	if r.lobby != nil {
+		r.lobby.Stop()
+	}
+
+	if limit := r.timeLimit(); limit > 0 {
+		r.clock = time.AfterFunc(limit, func() {
+			r.send(roomEvent{kind: ROOM_EVENT_TIME_UP, puzzle: puzzle})
+		})
+	}
+
+	if r.hub.OnPuzzleCreated != nil {
+		r.puzzleCreated(puzzle, startedAt)
+	}
+
+	for _, client := range r.Clients {
+		r.deliver(client, &Message{
+			Type:     MESSAGE_TYPE_PUZZLE_ASSIGN,
+			Content:  puzzle,
+			RoomID:   r.ID,
+			SenderID: client.ID,
+			Details:  &Clock{TimeLimitMs: r.timeLimit().Milliseconds()},
+		})
+	}
+}
+
+// timeLimit is how long the players have to solve the puzzle, zero if they
+// have all the time they want
+func (r *Room) timeLimit() time.Duration {
+	return time.Duration(r.Settings.TimeLimit) * time.Second
+}
+
+// awaitPuzzle waits for the pool to fill a miss and passes the result back
+// to the room, so the room never blocks on generation
+func (r *Room) awaitPuzzle(key hectoc.PoolKey) {
+	ctx, cancel := context.WithTimeout(context.Background(), PUZZLE_WAIT_TIMEOUT)
+	defer cancel()
+
+	puzzle, err := r.hub.Pool.Get(ctx, key)
+
+	r.send(roomEvent{
+		kind:   ROOM_EVENT_PUZZLE,
+		puzzle: puzzle,
+		err:    err,
+	})
+}
+
+// record queues a store callback for the room's callback goroutine
+func (r *Room) record(callback func()) {
+	r.callbacks <- callback
+}
+
+func (r *Room) runCallbacks() {
+	for callback := range r.callbacks {
+		callback()
+	}
+}
+
+// puzzleCreated, submitted, hinted and ended hand the game's progress to the
+// hub's callbacks, which run on the room's callback goroutine so the game
+// never waits on the store
+func (r *Room) puzzleCreated(puzzle *hectoc.Hectoc, startedAt time.Time) {
+	r.record(func() {
+		r.hub.OnPuzzleCreated(r.ID, puzzle, startedAt)
+	})
+}
+
+func (r *Room) submitted(submission *store.SubmissionStruct) {
+	r.record(func() {
+		r.hub.OnSubmission(r.ID, submission)
+	})
+}
+
+func (r *Room) hinted(hint *store.Hint) {
+	r.record(func() {
+		r.hub.OnHint(r.ID, hint)
+	})
+}
+
+func (r *Room) ended(result *Result) {
+	r.record(func() {
+		r.hub.OnEnding(r.ID, result)
+	})
+}
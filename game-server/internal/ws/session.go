@@ -0,0 +1,200 @@
+package ws
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
+)
+
+// DEFAULT_RESUME_GRACE is how long a player whose connection dropped keeps
+// their seat, unless the hub says otherwise
+const DEFAULT_RESUME_GRACE = 30 * time.Second
+
+// Session is sent with room_created and join_success. The token lets the
+// player take their seat back after a dropped connection.
+type Session struct {
+	ResumeToken string `json:"resumeToken"`
+	// GraceMs is how long the seat is held after a disconnect
+	GraceMs int64 `json:"graceMs"`
+}
+
+// Resume is sent with resumed: what the player needs to pick the game back
+// up. The token replaces the one used to resume.
+type Resume struct {
+	Session
+	Clock
+	Puzzle    *hectoc.Hectoc `json:"puzzle,omitempty"`
+	ElapsedMs int64          `json:"elapsedMs"`
+	Missed    []*Message     `json:"missed"`
+}
+
+// seat holds the place of a player whose connection dropped, until they
+// resume or the grace window runs out
+type seat struct {
+	client *Client
+	missed []*Message
+	timer  *time.Timer
+}
+
+func newResumeToken() string {
+	b := make([]byte, 16)
+
+	// crypto/rand never fails on supported platforms
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// session issues a fresh resume token for a player
+func (r *Room) session(playerID string) Session {
+	token := newResumeToken()
+	r.tokens[playerID] = token
+
+	return Session{
+		ResumeToken: token,
+		GraceMs:     r.hub.ResumeGrace.Milliseconds(),
+	}
+}
+
+// canResume reports whether a joining client holds the resume token of its
+// seat
+func (r *Room) canResume(cl *Client) bool {
+	token, ok := r.tokens[cl.ID]
+
+	return ok && cl.ResumeToken != "" && cl.ResumeToken == token
+}
+
+// seated is the number of players in the room, connected or not
+func (r *Room) seated() int {
+	return len(r.Clients) + len(r.away)
+}
+
+// connectionLost holds the seat of a client whose connection died without
+// a goodbye, and tells the opponent. Without a grace window the client is
+// dropped at once, forfeiting a game in progress.
+func (r *Room) connectionLost(cl *Client) {
+	if !r.remove(cl) {
+		return
+	}
+
+	close(cl.Message)
+
+	if r.hub.ResumeGrace <= 0 {
+		delete(r.tokens, cl.ID)
+
+		if r.forfeit(cl) {
+			return
+		}
+	}
+
+	r.broadcast(&Message{
+		Type:     MESSAGE_TYPE_CONNECTION_LOST,
+		Content:  "Your opponent lost their connection",
+		RoomID:   r.ID,
+		SenderID: cl.ID,
+	})
+
+	if r.hub.ResumeGrace > 0 {
+		r.away[cl.ID] = &seat{
+			client: cl,
+			timer: time.AfterFunc(r.hub.ResumeGrace, func() {
+				r.send(roomEvent{kind: ROOM_EVENT_EXPIRED, client: cl})
+			}),
+		}
+	}
+
+	r.closeIfEmpty()
+}
+
+// resume seats a client that came back with its token, replacing its old
+// connection if that is still open, and replays what it missed
+func (r *Room) resume(cl *Client) {
+	var missed []*Message
+
+	if old, ok := r.Clients[cl.ID]; ok {
+		delete(r.Clients, cl.ID)
+		close(old.Message)
+	}
+
+	if s, ok := r.away[cl.ID]; ok {
+		s.timer.Stop()
+		missed = s.missed
+		delete(r.away, cl.ID)
+	}
+
+	r.Clients[cl.ID] = cl
+
+	resume := &Resume{
+		Session: r.session(cl.ID),
+		Clock:   Clock{TimeLimitMs: r.timeLimit().Milliseconds()},
+		Puzzle:  r.Puzzle,
+		Missed:  missed,
+	}
+
+	if r.Puzzle != nil {
+		resume.ElapsedMs = time.Since(r.startedAt).Milliseconds()
+	}
+
+	r.deliver(cl, &Message{
+		Type:    MESSAGE_TYPE_RESUMED,
+		Content: "Welcome back",
+		RoomID:  r.ID,
+		Details: resume,
+	})
+
+	for _, other := range r.Clients {
+		if other != cl {
+			r.deliver(other, &Message{
+				Type:     MESSAGE_TYPE_OPPONENT_RESUMED,
+				Content:  "Your opponent is back",
+				RoomID:   r.ID,
+				SenderID: cl.ID,
+			})
+		}
+	}
+}
+
+// abandon gives up the seat of a player who did not come back in time,
+// forfeiting a game in progress
+func (r *Room) abandon(cl *Client) {
+	if s, ok := r.away[cl.ID]; !ok || s.client != cl {
+		return
+	}
+
+	delete(r.away, cl.ID)
+	delete(r.tokens, cl.ID)
+
+	if r.forfeit(cl) {
+		return
+	}
+
+	r.broadcast(&Message{
+		Type:     MESSAGE_TYPE_OPPONENT_LEFT,
+		Content:  "Your opponent did not come back",
+		RoomID:   r.ID,
+		SenderID: cl.ID,
+	})
+
+	r.closeIfEmpty()
+}
+
+// vacate releases every held seat, once the game is over
+func (r *Room) vacate() {
+	for id, s := range r.away {
+		s.timer.Stop()
+		delete(r.away, id)
+		delete(r.tokens, id)
+	}
+}
+
+// hold keeps a message for a player who is away, up to a client queue's
+// worth; the oldest go first
+func (s *seat) hold(msg *Message) {
+	if len(s.missed) == CLIENT_QUEUE_SIZE {
+		s.missed = s.missed[1:]
+	}
+
+	s.missed = append(s.missed, msg)
+}
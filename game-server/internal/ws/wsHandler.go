@@ -1,10 +1,12 @@
 package ws
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/eclairjit/hecto-clash-hf/game-server/internal/store"
 	"github.com/eclairjit/hecto-clash-hf/game-server/pkg/hectoc"
@@ -62,146 +64,296 @@ func Upgrade(w http.ResponseWriter, r *http.Request) (*websocket.Conn, error) {
 //     cl.readMessage(h.hub)
 // }
 
-func isNumber(char string) bool {
-    return regexp.MustCompile(`\d`).MatchString(char)
-}
+func (r *Room) handleSubmission(c *Client, msg *Message) {
+	submittedSeq, ok := msg.Content.(string)
 
-func countNumbers(sequence string) int {
-    count := 0
-    for _, char := range sequence {
-        if isNumber(string(char)) {
-            count++
-        }
-    }
-    return count
-}
+	if !ok {
+		r.deliver(c, &Message{
+			Type:    MESSAGE_TYPE_ERROR,
+			Content: "Invalid submission.",
+			RoomID:  r.ID,
+		})
+		return
+	}
 
-func validateSequence(hectocSeq, submittedSeq string) bool {
-    // Ensure the submitted sequence contains exactly the same number of numeric characters as the Hectoc sequence.
-    if countNumbers(submittedSeq) != len(hectocSeq) {
-        return false
-    }
-
-    
-    i, j := 0, 0
-
-    for i < len(hectocSeq) && j < len(submittedSeq) {
-        if isNumber(string(hectocSeq[i])) {
-            if isNumber(string(submittedSeq[j])) {
-                
-                if hectocSeq[i] != submittedSeq[j] {
-                    return false
-                }
-                i++
-                j++
-            } else {
-                
-                j++
-            }
-        } else {
-            
-            i++
-        }
-    }
-
-    return i == len(hectocSeq)
-}
+	if r.Puzzle == nil {
+		r.deliver(c, &Message{
+			Type:    MESSAGE_TYPE_ERROR,
+			Content: "The puzzle has not been assigned yet.",
+			RoomID:  r.ID,
+		})
+		return
+	}
 
-func (h *Hub) handleSubmission(c *Client, msg Message) {
-	if room, ok := h.Rooms[msg.RoomID]; ok {
-		hectocSeq := room.Puzzle.Problem
-		submittedSeq := msg.Content.(string)
+	// A hint costs the player time: they cannot submit until its penalty
+	// has run out
+	if wait := time.Until(r.penalisedUntil[c.ID]); wait > 0 {
+		r.deliver(c, &Message{
+			Type:    MESSAGE_TYPE_WRONG_SUBMISSION,
+			Content: fmt.Sprintf("Hint penalty: you can submit again in %ds.", int((wait+time.Second-1)/time.Second)),
+			RoomID:  r.ID,
+		})
+		return
+	}
 
-		if !validateSequence(hectocSeq, submittedSeq) {
-			c.Message <- &Message{
-				Type:    MESSAGE_TYPE_WRONG_SUBMISSION,
-				Content: "Invalid submission format. Please ensure your submission matches the Hectoc sequence.",
-				RoomID:  msg.RoomID,
-			}
-			return
-		}
+	verified, err := r.Puzzle.Verify(submittedSeq)
 
-		playerID, err := strconv.ParseInt(c.ID, 10, 64)
+	// Point the player at the exact spot a malformed submission breaks
+	var parseErr *hectoc.ParseError
 
-		if err != nil {
-			c.Message <- &Message{
-				Type:    MESSAGE_TYPE_ERROR,
-				Content: "Invalid player ID.",
-				RoomID:  msg.RoomID,
-			}
+	if errors.As(err, &parseErr) {
+		r.deliver(c, &Message{
+			Type:    MESSAGE_TYPE_WRONG_SUBMISSION,
+			Content: fmt.Sprintf("Malformed submission: %v.", parseErr),
+			RoomID:  r.ID,
+			Details: parseErr,
+		})
+		return
+	}
+
+	// Submissions too large or too deep to evaluate cheaply are turned
+	// away instead of tying up the room
+	var limitErr *hectoc.LimitError
+
+	if errors.Is(err, hectoc.ErrTooComplex) && errors.As(err, &limitErr) {
+		r.deliver(c, &Message{
+			Type:    MESSAGE_TYPE_WRONG_SUBMISSION,
+			Content: fmt.Sprintf("Submission too complex: %v.", limitErr),
+			RoomID:  r.ID,
+			Details: limitErr,
+		})
+		return
+	}
+
+	// Answers that break the room's rules, such as using the digits out
+	// of order, are rejected before they count as an attempt
+	var ruleErr *hectoc.RuleError
+
+	if errors.As(err, &ruleErr) {
+		r.deliver(c, &Message{
+			Type:    MESSAGE_TYPE_WRONG_SUBMISSION,
+			Content: fmt.Sprintf("Invalid submission: %v.", ruleErr),
+			RoomID:  r.ID,
+			Details: ruleErr,
+		})
+		return
+	}
+
+	playerID, err := strconv.ParseInt(c.ID, 10, 64)
+
+	if err != nil {
+		r.deliver(c, &Message{
+			Type:    MESSAGE_TYPE_ERROR,
+			Content: "Invalid player ID.",
+			RoomID:  r.ID,
+		})
+		return
+	}
+
+	submission := &store.SubmissionStruct{
+		PlayerID:   playerID,
+		Submission: submittedSeq,
+	}
+
+	// Equivalent submissions share a canonical form, so a repeat is turned
+	// away instead of being checked and recorded again
+	if canonical, err := hectoc.Canonicalize(submittedSeq); err == nil {
+		if r.tried[c.ID][canonical] {
+			r.deliver(c, &Message{
+				Type:    MESSAGE_TYPE_WRONG_SUBMISSION,
+				Content: "You have already submitted this answer, or one equivalent to it.",
+				RoomID:  r.ID,
+			})
 			return
 		}
 
-		submission := &store.SubmissionStruct{
-			PlayerID:   playerID,
-			Submission: submittedSeq,
+		if r.tried[c.ID] == nil {
+			r.tried[c.ID] = make(map[string]bool)
 		}
+		r.tried[c.ID][canonical] = true
 
-		verified, err := hectoc.Verify(msg.Content.(string))
+		submission.CanonicalSubmission = canonical
+	}
 
-		if verified {
-			submission.IsCorrect = true
+	if verified {
+		submission.IsCorrect = true
 
-			if h.OnSubmission != nil {
-				h.OnSubmission(msg.RoomID, submission)
-			}
+		if r.hub.OnSubmission != nil {
+			r.submitted(submission)
+		}
+
+		var opponentID int64
 
-			var opponentID int64
+		// Notify both users and end the game
+		for _, cl := range r.Clients {
+			if cl.ID == c.ID {
+				content := "Congratulations! You have submitted the correct answer."
 
-			// Notify both users and end the game
-			for _, cl := range room.Clients {
-				if cl.ID == c.ID {
-					cl.Message <- &Message{
-						Type:    MESSAGE_TYPE_CORRECT_SUBMISSION,
-						Content: "Congratulations! You have submitted the correct answer.",
-						RoomID:  msg.RoomID,
-					}
+				if index := r.Puzzle.SolutionIndex(submittedSeq); index >= 0 {
+					content += fmt.Sprintf(" You found solution #%d of %d.", index+1, len(r.Puzzle.Solutions))
+				}
+
+				r.deliver(cl, &Message{
+					Type:    MESSAGE_TYPE_CORRECT_SUBMISSION,
+					Content: content,
+					RoomID:  r.ID,
+				})
+			} else {
+				opponentID, err = strconv.ParseInt(cl.ID, 10, 64)
+
+				if err != nil {
+					r.deliver(cl, &Message{
+						Type:    MESSAGE_TYPE_ERROR,
+						Content: "Invalid opponent ID.",
+						RoomID:  r.ID,
+					})
 				} else {
-					opponentID, err = strconv.ParseInt(cl.ID, 10, 64)
-
-					if err != nil {
-						cl.Message <- &Message{
-							Type:    MESSAGE_TYPE_ERROR,
-							Content: "Invalid opponent ID.",
-							RoomID:  msg.RoomID,
-						}
-						continue
-					}
-
-					cl.Message <- &Message{
+					r.deliver(cl, &Message{
 						Type:    MESSAGE_TYPE_END,
 						Content: "Game over! Your opponent has submitted the correct answer.",
-						RoomID:  msg.RoomID,
-					}
-				}
-
-				close(cl.Message)
-
-				if h.OnEnding != nil {
-					h.OnEnding(msg.RoomID, playerID, opponentID)
+						RoomID:  r.ID,
+					})
 				}
 			}
-			delete(h.Rooms, msg.RoomID)
-		} else if err != nil {
-			// Notify only the submitting user
-			c.Message <- &Message{
-				Type:   MESSAGE_TYPE_ERROR,
-				Content: fmt.Sprintf("Error verifying submission: %v", err),
-				RoomID: msg.RoomID,
-			}
-		} else {
-			submission.IsCorrect = false
-			
-			if h.OnSubmission != nil {
-				h.OnSubmission(msg.RoomID, submission)
-			}
+		}
 
-			// Notify only the submitting user
-			c.Message <- &Message{
-				Type:    MESSAGE_TYPE_WRONG_SUBMISSION,
-				Content: fmt.Sprintf("Incorrect submission. Try again. Error: %v", err),
-				RoomID:  msg.RoomID,
+		// An opponent who is away still loses
+		for id := range r.away {
+			if opponentID, err = strconv.ParseInt(id, 10, 64); err != nil {
+				opponentID = 0
 			}
 		}
+
+		if r.hub.OnEnding != nil && opponentID != 0 {
+			r.ended(&Result{
+				WinnerID:   playerID,
+				LoserID:    opponentID,
+				Reason:     END_REASON_SOLVED,
+				Submission: submittedSeq,
+				Rated:      !r.Settings.Practice,
+			})
+		}
+
+		r.finish()
+	} else if err != nil {
+		// Notify only the submitting user
+		r.deliver(c, &Message{
+			Type:    MESSAGE_TYPE_ERROR,
+			Content: fmt.Sprintf("Error verifying submission: %v", err),
+			RoomID:  r.ID,
+		})
+	} else {
+		submission.IsCorrect = false
+
+		if r.hub.OnSubmission != nil {
+			r.submitted(submission)
+		}
+
+		// Notify only the submitting user, walking them through how their
+		// expression evaluates
+		wrong := &Message{
+			Type:    MESSAGE_TYPE_WRONG_SUBMISSION,
+			Content: "Incorrect submission. Try again.",
+			RoomID:  r.ID,
+		}
+
+		if explanation, err := hectoc.Explain(submittedSeq); err == nil {
+			wrong.Content = fmt.Sprintf("Incorrect submission. Your expression evaluates to %s, not %d. Try again.", explanation.Value, r.Puzzle.Spec.Target)
+			wrong.Details = explanation
+		}
+
+		r.deliver(c, wrong)
 	}
-}
\ No newline at end of file
+}
+
+// HintRequest is the content of a hint_request message
+type HintRequest struct {
+	Level   int    `json:"level"`
+	Partial string `json:"partial"`
+}
+
+func (r *Room) handleHintRequest(c *Client, msg *Message) {
+	if !r.Settings.Practice {
+		r.deliver(c, &Message{
+			Type:    MESSAGE_TYPE_ERROR,
+			Content: "Hints are only available in practice rooms.",
+			RoomID:  r.ID,
+		})
+		return
+	}
+
+	if r.Puzzle == nil {
+		r.deliver(c, &Message{
+			Type:    MESSAGE_TYPE_ERROR,
+			Content: "The puzzle has not been assigned yet.",
+			RoomID:  r.ID,
+		})
+		return
+	}
+
+	var req HintRequest
+
+	// Content arrives as a generic JSON value, so decode it once more
+	raw, err := json.Marshal(msg.Content)
+
+	if err == nil {
+		err = json.Unmarshal(raw, &req)
+	}
+
+	if err != nil {
+		r.deliver(c, &Message{
+			Type:    MESSAGE_TYPE_ERROR,
+			Content: "Invalid hint request.",
+			RoomID:  r.ID,
+		})
+		return
+	}
+
+	clue, err := r.Puzzle.Hint(req.Partial, req.Level)
+
+	if err != nil {
+		r.deliver(c, &Message{
+			Type:    MESSAGE_TYPE_ERROR,
+			Content: fmt.Sprintf("No hint available: %v.", err),
+			RoomID:  r.ID,
+		})
+		return
+	}
+
+	playerID, err := strconv.ParseInt(c.ID, 10, 64)
+
+	if err != nil {
+		r.deliver(c, &Message{
+			Type:    MESSAGE_TYPE_ERROR,
+			Content: "Invalid player ID.",
+			RoomID:  r.ID,
+		})
+		return
+	}
+
+	penalty := time.Duration(r.Settings.HintPenalty) * time.Second
+
+	// Penalties for several hints add up
+	until := r.penalisedUntil[c.ID]
+	if now := time.Now(); until.Before(now) {
+		until = now
+	}
+	r.penalisedUntil[c.ID] = until.Add(penalty)
+
+	// The hint and its penalty are recorded against the player
+	if r.hub.OnHint != nil {
+		r.hinted(&store.Hint{
+			PlayerID:  playerID,
+			Partial:   req.Partial,
+			Level:     clue.Level,
+			PenaltyMs: penalty.Milliseconds(),
+		})
+	}
+
+	r.deliver(c, &Message{
+		Type:    MESSAGE_TYPE_HINT,
+		Content: fmt.Sprintf("%s (+%ds)", clue.Message, r.Settings.HintPenalty),
+		RoomID:  r.ID,
+		Details: clue,
+	})
+}
@@ -0,0 +1,97 @@
+package hectoc
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// BankEntry is a puzzle whose solvability and difficulty were computed ahead
+// of time, e.g. by cmd/puzzlebank
+type BankEntry struct {
+	Problem       string `json:"problem"`
+	SolutionCount int    `json:"solutionCount"`
+	Difficulty    int    `json:"difficulty"`
+}
+
+// Bank holds the solvable puzzles of one spec, ordered by difficulty
+type Bank struct {
+	spec    Spec
+	entries []BankEntry
+}
+
+var (
+	banksMu sync.RWMutex
+	banks   = make(map[Spec]*Bank)
+)
+
+// NewBank builds a bank from precomputed entries, dropping any that do not
+// fit the spec or have no solutions
+func NewBank(spec Spec, entries []BankEntry) *Bank {
+	b := &Bank{spec: spec}
+
+	for _, e := range entries {
+		if spec.Matches(e.Problem) && e.SolutionCount > 0 {
+			b.entries = append(b.entries, e)
+		}
+	}
+
+	sort.SliceStable(b.entries, func(i, j int) bool {
+		return b.entries[i].Difficulty < b.entries[j].Difficulty
+	})
+
+	return b
+}
+
+// Spec returns the spec the bank's puzzles belong to
+func (b *Bank) Spec() Spec {
+	return b.spec
+}
+
+// Len returns the number of puzzles in the bank
+func (b *Bank) Len() int {
+	return len(b.entries)
+}
+
+// Draw picks a random puzzle whose difficulty lies in [minScore, maxScore]
+func (b *Bank) Draw(r *rand.Rand, minScore, maxScore int) (BankEntry, bool) {
+	lo := sort.Search(len(b.entries), func(i int) bool {
+		return b.entries[i].Difficulty >= minScore
+	})
+
+	hi := sort.Search(len(b.entries), func(i int) bool {
+		return b.entries[i].Difficulty > maxScore
+	})
+
+	if lo >= hi {
+		return BankEntry{}, false
+	}
+
+	return b.entries[lo+r.Intn(hi-lo)], true
+}
+
+// UseBank makes puzzle generation for the bank's spec draw from the bank
+// instead of dealing and solving random sequences
+func UseBank(b *Bank) {
+	banksMu.Lock()
+	defer banksMu.Unlock()
+
+	banks[bankKey(b.spec)] = b
+}
+
+func bankFor(s Spec) *Bank {
+	banksMu.RLock()
+	defer banksMu.RUnlock()
+
+	return banks[bankKey(s)]
+}
+
+// bankKey drops the parts of a spec that do not change which puzzles are
+// solvable or how hard they are. The solver never builds decimals,
+// factorials or roots.
+func bankKey(s Spec) Spec {
+	s.Rules.Decimals = false
+	s.Rules.Factorial = false
+	s.Rules.SquareRoot = false
+	return s
+}
@@ -0,0 +1,249 @@
+package hectoc
+
+import (
+	"sort"
+	"strings"
+)
+
+// Kinds of canonical nodes
+const (
+	canonAtom = iota
+	canonSum
+	canonProduct
+	canonPower
+)
+
+// canon is an expression in canonical form. Sums and products are flattened
+// into operand lists whose order no longer matters; inverted marks a
+// subtracted term of a sum or a divisor of a product.
+type canon struct {
+	kind     int
+	text     string
+	operands []canonOperand
+	base     *canon
+	exp      *canon
+	rendered string
+}
+
+type canonOperand struct {
+	inverted bool
+	node     *canon
+}
+
+// canonicalize rewrites a tree into canonical form
+func canonicalize(e *Expr) *canon {
+	switch e.Kind {
+	case NUMBER_EXPR:
+		return &canon{kind: canonAtom, text: e.Value}
+
+	case UNARY_EXPR:
+		switch e.Op {
+		case ADD:
+			// Unary plus changes nothing
+			return canonicalize(e.X)
+		case SUBTRACT:
+			// Unary minus is a product with a single factor
+			return canonicalizeProduct(true, factorSide{e.X, false})
+		case FACTORIAL:
+			return &canon{kind: canonAtom, text: wrapUnlessAtom(canonicalize(e.X)) + FACTORIAL}
+		case SQUARE_ROOT:
+			return &canon{kind: canonAtom, text: SQUARE_ROOT + wrapUnlessAtom(canonicalize(e.X))}
+		}
+	}
+
+	switch e.Op {
+	case ADD, SUBTRACT:
+		var terms []canonOperand
+		terms = appendTerms(terms, canonicalize(e.X), false)
+		terms = appendTerms(terms, canonicalize(e.Y), e.Op == SUBTRACT)
+		return makeSum(terms)
+
+	case MULTIPLY, DIVIDE:
+		return canonicalizeProduct(false, factorSide{e.X, false}, factorSide{e.Y, e.Op == DIVIDE})
+
+	case POWER:
+		return &canon{
+			kind: canonPower,
+			base: canonicalize(e.X),
+			exp:  canonicalize(e.Y),
+		}
+	}
+
+	return &canon{kind: canonAtom, text: e.String()}
+}
+
+// factorSide is an operand of a product and whether it divides
+type factorSide struct {
+	e       *Expr
+	divisor bool
+}
+
+// canonicalizeProduct flattens the sides into one product, pulling every
+// sign out in front of it
+func canonicalizeProduct(negative bool, sides ...factorSide) *canon {
+	var factors []canonOperand
+
+	for _, side := range sides {
+		e := side.e
+
+		for e.Kind == UNARY_EXPR && e.Op == SUBTRACT {
+			negative = !negative
+			e = e.X
+		}
+
+		c := canonicalize(e)
+
+		// Pull the sign out of a single negated term
+		if c.kind == canonSum && len(c.operands) == 1 {
+			negative = negative != c.operands[0].inverted
+			c = c.operands[0].node
+		}
+
+		if c.kind == canonProduct {
+			for _, f := range c.operands {
+				factors = append(factors, canonOperand{f.inverted != side.divisor, f.node})
+			}
+		} else {
+			factors = append(factors, canonOperand{side.divisor, c})
+		}
+	}
+
+	var product *canon
+	switch {
+	case len(factors) == 1 && !factors[0].inverted:
+		product = factors[0].node
+	case len(factors) == 0:
+		product = &canon{kind: canonAtom, text: "1"}
+	default:
+		sortOperands(factors)
+		product = &canon{kind: canonProduct, operands: factors}
+	}
+
+	if negative {
+		return makeSum(appendTerms(nil, product, true))
+	}
+	return product
+}
+
+// appendTerms adds c to a sum, flattening nested sums
+func appendTerms(terms []canonOperand, c *canon, subtract bool) []canonOperand {
+	if c.kind == canonSum {
+		for _, t := range c.operands {
+			terms = append(terms, canonOperand{t.inverted != subtract, t.node})
+		}
+		return terms
+	}
+
+	return append(terms, canonOperand{subtract, c})
+}
+
+func makeSum(terms []canonOperand) *canon {
+	if len(terms) == 1 && !terms[0].inverted {
+		return terms[0].node
+	}
+
+	sortOperands(terms)
+	return &canon{kind: canonSum, operands: terms}
+}
+
+// sortOperands orders operands by their rendering, putting plain operands
+// before inverted ones when the renderings are equal
+func sortOperands(operands []canonOperand) {
+	sort.SliceStable(operands, func(a, b int) bool {
+		sa, sb := operands[a].node.String(), operands[b].node.String()
+		if sa != sb {
+			return sa < sb
+		}
+		return !operands[a].inverted && operands[b].inverted
+	})
+}
+
+// String renders the canonical form as a valid expression
+func (c *canon) String() string {
+	if c.rendered == "" {
+		c.rendered = c.render()
+	}
+	return c.rendered
+}
+
+func (c *canon) render() string {
+	switch c.kind {
+	case canonSum:
+		var sb strings.Builder
+		for i, t := range c.operands {
+			switch {
+			case t.inverted:
+				sb.WriteString(SUBTRACT)
+			case i > 0:
+				sb.WriteString(ADD)
+			}
+			sb.WriteString(t.node.String())
+		}
+		return sb.String()
+
+	case canonProduct:
+		var numerator, denominator []string
+		for _, f := range c.operands {
+			text := f.node.String()
+			if f.node.kind == canonSum {
+				text = "(" + text + ")"
+			}
+
+			if f.inverted {
+				denominator = append(denominator, text)
+			} else {
+				numerator = append(numerator, text)
+			}
+		}
+
+		if len(numerator) == 0 {
+			numerator = []string{"1"}
+		}
+
+		s := strings.Join(numerator, MULTIPLY)
+		for _, d := range denominator {
+			s += DIVIDE + d
+		}
+		return s
+
+	case canonPower:
+		return wrapUnlessAtom(c.base) + POWER + wrapUnlessAtom(c.exp)
+	}
+
+	return c.text
+}
+
+func wrapUnlessAtom(c *canon) string {
+	if c.kind == canonAtom {
+		return c.String()
+	}
+	return "(" + c.String() + ")"
+}
+
+// Canonicalize rewrites an expression into a normal form in which
+// rearrangements of sums and products and redundant parentheses no longer
+// show. Two expressions are equivalent if their canonical forms are equal.
+func Canonicalize(expression string) (string, error) {
+	e, err := Parse(expression)
+	if err != nil {
+		return "", err
+	}
+
+	return canonicalize(e).String(), nil
+}
+
+// Equivalent reports whether two expressions differ only by rearranging
+// sums and products or by redundant parentheses
+func Equivalent(a, b string) (bool, error) {
+	ca, err := Canonicalize(a)
+	if err != nil {
+		return false, err
+	}
+
+	cb, err := Canonicalize(b)
+	if err != nil {
+		return false, err
+	}
+
+	return ca == cb, nil
+}
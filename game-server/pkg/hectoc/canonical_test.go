@@ -0,0 +1,65 @@
+package hectoc
+
+import "testing"
+
+func TestEquivalent(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		// Commutativity and associativity collapse
+		{"1+2+3", "3+(2+1)", true},
+		{"1*2*3", "(3*2)*1", true},
+		{"1+2*3", "(2*3)+1", true},
+		{"1-2+3", "1+3-2", true},
+		{"1-2-3", "1-(2+3)", true},
+		{"1/2/3", "1/(2*3)", true},
+		{"-1+2", "2-1", true},
+		{"-(1+2)", "-1-2", true},
+		// Redundant parentheses
+		{"((1+2))", "1+2", true},
+		{"(1*2)+(3*4)", "1*2+3*4", true},
+		// Same value, different expression
+		{"1-2-3", "1-(2-3)", false},
+		{"1/2/3", "1/(2/3)", false},
+		{"2^3^2", "(2^3)^2", false},
+		{"1+2*3", "(1+2)*3", false},
+		{"2^3", "3^2", false},
+		{"1-2", "2-1", false},
+		{"1/2", "2/1", false},
+		{"12+3", "1+23", false},
+		{"1*(2+3)", "1*2+1*3", false},
+		{"1+2", "1+2+0", false},
+	}
+
+	for _, tt := range tests {
+		got, err := Equivalent(tt.a, tt.b)
+
+		if err != nil {
+			t.Fatalf("Equivalent(%q, %q): %v", tt.a, tt.b, err)
+		}
+
+		if got != tt.want {
+			t.Errorf("Equivalent(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSolveKeepsOneOfEachEquivalentSolution(t *testing.T) {
+	for _, problem := range []string{"123456", "999999", "112358"} {
+		solutions := Solve(problem)
+		seen := make(map[string]string, len(solutions))
+
+		for _, solution := range solutions {
+			key, err := Canonicalize(solution)
+			if err != nil {
+				t.Fatalf("Canonicalize(%q): %v", solution, err)
+			}
+
+			if other, dup := seen[key]; dup {
+				t.Fatalf("%s: %q and %q are both solutions but equivalent", problem, other, solution)
+			}
+			seen[key] = solution
+		}
+	}
+}
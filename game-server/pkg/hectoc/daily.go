@@ -0,0 +1,16 @@
+package hectoc
+
+import "time"
+
+// DailySeed is the seed of the daily puzzle for a date, e.g. 20250403. Only
+// the UTC calendar day matters.
+func DailySeed(date time.Time) int64 {
+	year, month, day := date.UTC().Date()
+
+	return int64(year*10000 + int(month)*100 + day)
+}
+
+// Daily returns the classic puzzle everyone gets on a UTC date
+func Daily(date time.Time) (*Hectoc, error) {
+	return NewSeededGenerator(DefaultSpec, DailySeed(date)).Generate()
+}
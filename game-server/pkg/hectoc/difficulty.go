@@ -0,0 +1,163 @@
+package hectoc
+
+import (
+	"math"
+)
+
+// MAX_DIFFICULTY is the score of the hardest possible puzzle
+const MAX_DIFFICULTY = 100
+
+// DifficultyBands name ranges of difficulty scores. Most classic puzzles
+// score between 10 and 40, so the bands split that range rather than the
+// whole scale.
+var DifficultyBands = map[string][2]int{
+	"easy":   {0, 19},
+	"medium": {20, 34},
+	"hard":   {35, MAX_DIFFICULTY},
+}
+
+// Difficulty summarises how hard a puzzle is, based on what the solver
+// found. An unsolvable puzzle has no solutions, a zero score and a MinDepth
+// of -1.
+type Difficulty struct {
+	Score              int  `json:"score"`
+	SolutionCount      int  `json:"solutionCount"`
+	MinDepth           int  `json:"minDepth"`
+	NeedsConcatenation bool `json:"needsConcatenation"`
+	NeedsPower         bool `json:"needsPower"`
+}
+
+// Weights of the difficulty score. Scarcity dominates: a puzzle with a
+// single solution is hard however shallow that solution is.
+const (
+	scarcityWeight      = 50
+	scarcityReference   = 200
+	depthWeight         = 5
+	maxDepthScore       = 25
+	concatenationWeight = 10
+	powerWeight         = 15
+)
+
+// Assess rates a classic puzzle
+func Assess(problem string) Difficulty {
+	return DefaultSpec.Assess(problem)
+}
+
+// Assess rates a puzzle of this spec
+func (s Spec) Assess(problem string) Difficulty {
+	return s.assess(problem, s.Solve(problem))
+}
+
+// assess rates a puzzle whose solutions are already known
+func (s Spec) assess(problem string, solutions []string) Difficulty {
+	d := Difficulty{
+		SolutionCount: len(solutions),
+		MinDepth:      -1,
+	}
+
+	if len(solutions) == 0 {
+		return d
+	}
+
+	// A listed solution that avoids concatenation or powers settles whether
+	// they are needed; the solver only runs again when none does, since
+	// the list may be capped
+	concatenationFree, powerFree := false, false
+
+	for _, solution := range solutions {
+		e, err := Parse(solution)
+		if err != nil {
+			continue
+		}
+
+		if depth := e.depth(); d.MinDepth < 0 || depth < d.MinDepth {
+			d.MinDepth = depth
+		}
+
+		concatenationFree = concatenationFree || !e.uses(isConcatenation)
+		powerFree = powerFree || !e.uses(isPower)
+	}
+
+	target := rat{s.Target, 1}
+
+	if !concatenationFree {
+		withoutConcatenation := s.Rules.grammar()
+		withoutConcatenation.concatenation = false
+		d.NeedsConcatenation = !newSolver(problem, 0, withoutConcatenation).solvable(target)
+	}
+
+	if !powerFree {
+		withoutPower := s.Rules.grammar()
+		withoutPower.operators = []string{ADD, SUBTRACT, MULTIPLY, DIVIDE}
+		d.NeedsPower = !newSolver(problem, 0, withoutPower).solvable(target)
+	}
+
+	d.Score = d.score()
+
+	return d
+}
+
+func (d Difficulty) score() int {
+	scarcity := 1 - math.Log(float64(d.SolutionCount))/math.Log(scarcityReference)
+	score := int(math.Round(scarcityWeight * math.Max(scarcity, 0)))
+
+	score += min(depthWeight*d.MinDepth, maxDepthScore)
+
+	if d.NeedsConcatenation {
+		score += concatenationWeight
+	}
+
+	if d.NeedsPower {
+		score += powerWeight
+	}
+
+	return min(score, MAX_DIFFICULTY)
+}
+
+// uses reports whether any node of the tree satisfies the predicate
+func (e *Expr) uses(predicate func(*Expr) bool) bool {
+	if predicate(e) {
+		return true
+	}
+
+	switch e.Kind {
+	case UNARY_EXPR:
+		return e.X.uses(predicate)
+	case BINARY_EXPR:
+		return e.X.uses(predicate) || e.Y.uses(predicate)
+	}
+
+	return false
+}
+
+// isConcatenation matches a number made of several digits
+func isConcatenation(e *Expr) bool {
+	return e.Kind == NUMBER_EXPR && len(e.Value) > 1
+}
+
+func isPower(e *Expr) bool {
+	return e.Kind == BINARY_EXPR && e.Op == POWER
+}
+
+// depth is the number of operators on the longest path from the root to a
+// leaf. Unary minus counts as one, like the multiplication by -1 it stands
+// for; unary plus does not count.
+func (e *Expr) depth() int {
+	switch e.Kind {
+	case UNARY_EXPR:
+		if e.Op == ADD {
+			return e.X.depth()
+		}
+		return 1 + e.X.depth()
+	case BINARY_EXPR:
+		return 1 + max(e.X.depth(), e.Y.depth())
+	}
+
+	return 0
+}
+
+// GenerateWithDifficulty returns a classic puzzle whose score lies in
+// [minScore, maxScore]
+func GenerateWithDifficulty(minScore, maxScore int) (*Hectoc, error) {
+	return DefaultSpec.GenerateWithDifficulty(minScore, maxScore)
+}
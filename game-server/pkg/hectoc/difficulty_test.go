@@ -0,0 +1,75 @@
+package hectoc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAssessUnsolvable(t *testing.T) {
+	spec := Spec{Digits: 1, Target: 100, Alphabet: "1"}
+
+	d := spec.Assess("1")
+	if d.Score != 0 || d.SolutionCount != 0 || d.MinDepth != -1 {
+		t.Fatalf("got %+v, want a zero score and a MinDepth of -1", d)
+	}
+}
+
+func TestAssessMatchesSolutions(t *testing.T) {
+	for _, problem := range []string{"123456", "999999", "112358", "471298"} {
+		d := Assess(problem)
+		solutions := Solve(problem)
+
+		if d.SolutionCount != len(solutions) {
+			t.Fatalf("%s: SolutionCount = %d, want %d", problem, d.SolutionCount, len(solutions))
+		}
+
+		if d.Score < 0 || d.Score > MAX_DIFFICULTY {
+			t.Fatalf("%s: score %d outside [0, %d]", problem, d.Score, MAX_DIFFICULTY)
+		}
+
+		if len(solutions) > 0 && d.MinDepth < 1 {
+			t.Fatalf("%s: MinDepth = %d for a solvable puzzle", problem, d.MinDepth)
+		}
+	}
+}
+
+func TestDifficultyBandsCoverTheScale(t *testing.T) {
+	covered := make([]int, MAX_DIFFICULTY+1)
+
+	for name, band := range DifficultyBands {
+		if band[0] > band[1] {
+			t.Fatalf("%s: band %v is empty", name, band)
+		}
+
+		for score := band[0]; score <= band[1]; score++ {
+			covered[score]++
+		}
+	}
+
+	for score, n := range covered {
+		if n != 1 {
+			t.Fatalf("score %d falls in %d bands, want exactly one", score, n)
+		}
+	}
+}
+
+func TestGenerateWithDifficultyStaysInBand(t *testing.T) {
+	for name, band := range DifficultyBands {
+		h, err := GenerateWithDifficulty(band[0], band[1])
+		if err != nil {
+			t.Fatalf("%s: GenerateWithDifficulty(%d, %d): %v", name, band[0], band[1], err)
+		}
+
+		if h.Difficulty.Score < band[0] || h.Difficulty.Score > band[1] {
+			t.Fatalf("%s: got score %d, want one in [%d, %d]", name, h.Difficulty.Score, band[0], band[1])
+		}
+
+		if h.Difficulty != Assess(h.Problem) {
+			t.Fatalf("%s: puzzle says %+v, Assess says %+v", h.Problem, h.Difficulty, Assess(h.Problem))
+		}
+	}
+
+	if _, err := GenerateWithDifficulty(MAX_DIFFICULTY, 0); !errors.Is(err, ErrNoPuzzle) {
+		t.Fatalf("GenerateWithDifficulty with an empty band = %v, want ErrNoPuzzle", err)
+	}
+}
@@ -0,0 +1,80 @@
+package hectoc
+
+import "math/big"
+
+// Step is one reduction of an expression, such as 7*8 = 56. Pos and End
+// are the byte offsets of the reduced part of the source.
+type Step struct {
+	Expression string `json:"expression"`
+	Value      string `json:"value"`
+	Pos        int    `json:"pos"`
+	End        int    `json:"end"`
+}
+
+func (s Step) String() string {
+	return s.Expression + " = " + s.Value
+}
+
+// Explanation lists the reductions that evaluate an expression, in the
+// order they are applied, and the value they arrive at
+type Explanation struct {
+	Steps []Step `json:"steps"`
+	Value string `json:"value"`
+}
+
+// Explain evaluates an expression one operator at a time, innermost first
+// and left to right, recording every reduction. It fails like Parse and
+// Evaluate do.
+func Explain(expression string) (*Explanation, error) {
+	e, err := Parse(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation := &Explanation{Steps: []Step{}}
+
+	value, err := e.reduce(func(e *Expr, operands []*big.Rat, result *big.Rat) {
+		// A unary plus changes nothing and a sign on a number is just a
+		// negative number, neither is worth a step
+		if e.Kind == UNARY_EXPR && (e.Op == ADD || e.Op == SUBTRACT && e.X.Kind == NUMBER_EXPR) {
+			return
+		}
+
+		explanation.Steps = append(explanation.Steps, Step{
+			Expression: describeStep(e, operands),
+			Value:      result.RatString(),
+			Pos:        e.Pos,
+			End:        e.End,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	explanation.Value = value.RatString()
+
+	return explanation, nil
+}
+
+// describeStep writes an operator applied to the values of its operands
+func describeStep(e *Expr, operands []*big.Rat) string {
+	if e.Kind == BINARY_EXPR {
+		return describeOperand(operands[0]) + e.Op + describeOperand(operands[1])
+	}
+
+	if e.Op == FACTORIAL {
+		return describeOperand(operands[0]) + e.Op
+	}
+
+	return e.Op + describeOperand(operands[0])
+}
+
+// describeOperand writes a value, in parentheses unless it is a whole
+// number of at least zero
+func describeOperand(x *big.Rat) string {
+	if x.Sign() < 0 || !x.IsInt() {
+		return "(" + x.RatString() + ")"
+	}
+
+	return x.RatString()
+}
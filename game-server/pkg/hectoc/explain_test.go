@@ -0,0 +1,81 @@
+package hectoc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	tests := []struct {
+		expr  string
+		steps []string
+		value string
+	}{
+		{"7", nil, "7"},
+		{"+3", nil, "3"},
+		{"1+2*3", []string{"2*3 = 6", "1+6 = 7"}, "7"},
+		{"(1+2)*3", []string{"1+2 = 3", "3*3 = 9"}, "9"},
+		{"-1+2", []string{"(-1)+2 = 1"}, "1"},
+		{"-(1+2)", []string{"1+2 = 3", "-3 = -3"}, "-3"},
+		{"1/3-1", []string{"1/3 = 1/3", "(1/3)-1 = -2/3"}, "-2/3"},
+		{"2^3!", []string{"3! = 6", "2^6 = 64"}, "64"},
+		{"√(3+6)", []string{"3+6 = 9", "√9 = 3"}, "3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			x, err := Explain(tt.expr)
+			if err != nil {
+				t.Fatalf("Explain: %v", err)
+			}
+
+			if x.Value != tt.value || len(x.Steps) != len(tt.steps) {
+				t.Fatalf("got %v = %s, want %v = %s", x.Steps, x.Value, tt.steps, tt.value)
+			}
+
+			for i, step := range x.Steps {
+				if step.String() != tt.steps[i] {
+					t.Fatalf("step %d is %q, want %q", i, step.String(), tt.steps[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExplainStepPositions(t *testing.T) {
+	x, err := Explain("1+(2+3)*4")
+	if err != nil {
+		t.Fatalf("Explain: %v", err)
+	}
+
+	want := []Step{
+		{Expression: "2+3", Value: "5", Pos: 3, End: 6},
+		{Expression: "5*4", Value: "20", Pos: 3, End: 9},
+		{Expression: "1+20", Value: "21", Pos: 0, End: 9},
+	}
+
+	if len(x.Steps) != len(want) {
+		t.Fatalf("got %+v, want %+v", x.Steps, want)
+	}
+
+	for i := range want {
+		if x.Steps[i] != want[i] {
+			t.Fatalf("step %d is %+v, want %+v", i, x.Steps[i], want[i])
+		}
+	}
+}
+
+func TestExplainFails(t *testing.T) {
+	var pe *ParseError
+	if _, err := Explain("1+"); !errors.As(err, &pe) {
+		t.Fatalf("Explain(\"1+\") = %v, want a *ParseError", err)
+	}
+
+	if _, err := Explain("1/(2-2)"); !errors.Is(err, ErrDivisionByZero) {
+		t.Fatalf("Explain(\"1/(2-2)\") = %v, want ErrDivisionByZero", err)
+	}
+
+	if _, err := Explain("2^100"); !errors.Is(err, ErrTooComplex) {
+		t.Fatalf("Explain(\"2^100\") = %v, want ErrTooComplex", err)
+	}
+}
@@ -2,95 +2,137 @@ package hectoc
 
 import (
 	"math/rand"
-	"strconv"
-	"time"
+	"sync"
 )
 
 const MAX_ATTEMPTS = 100
 
 type Hectoc struct {
 	Problem 	string 		`json:"problem"`
+	Spec 		Spec 		`json:"spec"`
 	Solutions 	[]string 	`json:"solutions"`
+	Difficulty 	Difficulty 	`json:"difficulty"`
 }
 
-var insertions = []string{"+", "-", "*", "/", "^", "(", ")", ""}
-
-func generateSolutions(current string, remaining string, solutions *[]string, bracesParity int) {
-	if len(remaining) == 0 {
-		// Check if the current expression is valid
-		if bracesParity > 0 {
-			return
-		} else {
-			for i := 1; i <= bracesParity; i++ {
-				current += ")"
-			}
-		}
+// SolutionIndex returns the position of the solution equivalent to the given
+// expression, or -1 if the expression matches none of the known solutions
+func (h *Hectoc) SolutionIndex(expression string) int {
+	canonical, err := Canonicalize(expression)
 
-		verified, _ := Verify(current)
+	if err != nil {
+		return -1
+	}
 
-		if verified {
-			*solutions = append(*solutions, current)
+	for i, solution := range h.Solutions {
+		if c, err := Canonicalize(solution); err == nil && c == canonical {
+			return i
 		}
-
-		return
 	}
 
-	nextDigit := remaining[0]
-	newRemaining := remaining[1:]
+	return -1
+}
 
-	for _, insertion := range insertions {
-		if insertion == "(" {
-			bracesParity--
-		} else if insertion == ")" {
-			bracesParity++
-		}
+// Verify reports whether an expression uses the puzzle's digits, follows
+// the spec's rules and reaches its target. A broken rule is reported as a
+// *RuleError, a malformed expression as a *ParseError.
+func (h *Hectoc) Verify(expression string) (bool, error) {
+	e, err := Parse(expression)
 
-		newCurrent := current + insertion + string(nextDigit)
+	if err != nil {
+		return false, err
+	}
 
-		generateSolutions(newCurrent, newRemaining, solutions, bracesParity)
+	if err := h.Spec.Rules.Check(h.Problem, e); err != nil {
+		return false, err
 	}
+
+	return h.Spec.reaches(e)
 }
 
 func (h *Hectoc) solve() {
-	var solutions []string
+	// Enumerate every expression tree over the digits
+	h.Solutions = h.Spec.Solve(h.Problem)
+	h.Difficulty = h.Spec.assess(h.Problem, h.Solutions)
+}
 
-	// Generate all possible solutions
-	generateSolutions("", h.Problem, &solutions, 0)
-	h.Solutions = solutions
+// Generator deals puzzles of one spec from its own source of randomness, so
+// the same seed yields the same puzzles as long as the spec's bank, if any,
+// is the same. It is safe for concurrent use.
+type Generator struct {
+	spec Spec
+
+	mu sync.Mutex
+	r  *rand.Rand
 }
 
-var fallbacks = []string{"123456", "999541", "472319", "327924"}
+// NewGenerator creates a generator that draws from the given source
+func NewGenerator(spec Spec, src rand.Source) *Generator {
+	return &Generator{
+		spec: spec,
+		r:    rand.New(src),
+	}
+}
 
-func Generate() *Hectoc {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+// NewSeededGenerator creates a generator whose puzzles are reproducible from the seed
+func NewSeededGenerator(spec Spec, seed int64) *Generator {
+	return NewGenerator(spec, rand.NewSource(seed))
+}
 
-	for attempts:=1; attempts <= 100; attempts++ {
-		sequence := ""
+// Spec returns the spec the generator deals puzzles for
+func (g *Generator) Spec() Spec {
+	return g.spec
+}
 
-		for i:=1; i<=6; i++ {
-			digit := r.Intn(9) + 1
-			sequence += strconv.Itoa(digit)
-		}
+// Generate returns a solvable puzzle of any difficulty
+func (g *Generator) Generate() (*Hectoc, error) {
+	return g.GenerateWithDifficulty(0, MAX_DIFFICULTY)
+}
 
-		if _, found := excluded[sequence]; !found {
-			h := &Hectoc{
-				Problem: sequence,
-			}
+// GenerateWithDifficulty returns a solvable puzzle whose difficulty score
+// lies in [minScore, maxScore]. It draws from the spec's bank if one is in
+// use, and otherwise deals and solves random sequences.
+func (g *Generator) GenerateWithDifficulty(minScore, maxScore int) (*Hectoc, error) {
+	if err := g.spec.Validate(); err != nil {
+		return nil, err
+	}
 
-			h.solve()
+	if bank := bankFor(g.spec); bank != nil {
+		g.mu.Lock()
+		entry, ok := bank.Draw(g.r, minScore, maxScore)
+		g.mu.Unlock()
 
-			return h
+		if !ok {
+			return nil, ErrNoPuzzle
 		}
+
+		return g.spec.Puzzle(entry.Problem)
 	}
 
-	sequence := fallbacks[r.Intn(len(fallbacks))]
+	for attempts := 1; attempts <= MAX_ATTEMPTS; attempts++ {
+		g.mu.Lock()
+		problem := g.spec.deal(g.r)
+		g.mu.Unlock()
+
+		h := &Hectoc{
+			Problem: problem,
+			Spec:    g.spec,
+		}
+
+		h.solve()
 
-	h := &Hectoc{
-		Problem: sequence,
+		if len(h.Solutions) > 0 && h.Difficulty.Score >= minScore && h.Difficulty.Score <= maxScore {
+			return h, nil
+		}
 	}
 
-	h.solve()
+	return nil, ErrNoPuzzle
+}
 
-	return h
+var fallbacks = []string{"123456", "999541", "472319", "327924"}
 
+// Generate returns a classic six digit puzzle
+func Generate() *Hectoc {
+	h, _ := DefaultSpec.Generate()
+
+	return h
 }
\ No newline at end of file
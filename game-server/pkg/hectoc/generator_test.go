@@ -0,0 +1,125 @@
+package hectoc
+
+import (
+	"testing"
+	"time"
+)
+
+// problems deals n puzzles from a generator
+func problems(t *testing.T, g *Generator, n int) []string {
+	t.Helper()
+
+	dealt := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		h, err := g.Generate()
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+
+		dealt = append(dealt, h.Problem)
+	}
+
+	return dealt
+}
+
+func TestSeededGeneratorIsReproducible(t *testing.T) {
+	first := problems(t, NewSeededGenerator(DefaultSpec, 42), 5)
+	second := problems(t, NewSeededGenerator(DefaultSpec, 42), 5)
+	other := problems(t, NewSeededGenerator(DefaultSpec, 43), 5)
+
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("seed 42 dealt %v, then %v", first, second)
+		}
+	}
+
+	same := true
+	for i := range first {
+		same = same && first[i] == other[i]
+	}
+
+	if same {
+		t.Fatalf("seeds 42 and 43 both dealt %v", first)
+	}
+}
+
+func TestSeededGeneratorDrawsFromTheBank(t *testing.T) {
+	spec := Spec{Digits: 4, Target: 24, Alphabet: "1234"}
+	entries := []BankEntry{
+		{Problem: "1234", SolutionCount: 10, Difficulty: 10},
+		{Problem: "2341", SolutionCount: 5, Difficulty: 20},
+		{Problem: "3412", SolutionCount: 3, Difficulty: 30},
+		{Problem: "4123", SolutionCount: 1, Difficulty: 40},
+		{Problem: "1111", SolutionCount: 0, Difficulty: 50},
+	}
+
+	UseBank(NewBank(spec, entries))
+
+	draw := func(seed int64) []string {
+		g := NewSeededGenerator(spec, seed)
+
+		var dealt []string
+		for i := 0; i < 10; i++ {
+			h, err := g.GenerateWithDifficulty(15, 35)
+			if err != nil {
+				t.Fatalf("GenerateWithDifficulty: %v", err)
+			}
+
+			if h.Problem != "2341" && h.Problem != "3412" {
+				t.Fatalf("drew %s, want a bank puzzle scored between 15 and 35", h.Problem)
+			}
+
+			dealt = append(dealt, h.Problem)
+		}
+
+		return dealt
+	}
+
+	first, second := draw(7), draw(7)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("seed 7 drew %v, then %v", first, second)
+		}
+	}
+}
+
+func TestDailyIsReproducible(t *testing.T) {
+	morning := time.Date(2025, time.April, 3, 1, 0, 0, 0, time.UTC)
+	evening := time.Date(2025, time.April, 3, 23, 0, 0, 0, time.UTC)
+	// Still April 3rd in UTC
+	elsewhere := time.Date(2025, time.April, 2, 22, 0, 0, 0, time.FixedZone("UTC-5", -5*60*60))
+
+	if seed := DailySeed(morning); seed != 20250403 {
+		t.Fatalf("DailySeed = %d, want 20250403", seed)
+	}
+
+	want, err := Daily(morning)
+	if err != nil {
+		t.Fatalf("Daily: %v", err)
+	}
+
+	for _, date := range []time.Time{morning, evening, elsewhere} {
+		got, err := Daily(date)
+		if err != nil {
+			t.Fatalf("Daily(%v): %v", date, err)
+		}
+
+		if got.Problem != want.Problem {
+			t.Fatalf("Daily(%v) = %s, want %s", date, got.Problem, want.Problem)
+		}
+	}
+
+	distinct := map[string]bool{}
+	for day := 1; day <= 5; day++ {
+		h, err := Daily(time.Date(2025, time.April, day, 12, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatalf("Daily: %v", err)
+		}
+
+		distinct[h.Problem] = true
+	}
+
+	if len(distinct) < 2 {
+		t.Fatalf("five days dealt only %v", distinct)
+	}
+}
@@ -0,0 +1,159 @@
+package hectoc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Hint levels, from a nudge to the full answer
+const (
+	HINT_OPERATOR = 1
+	HINT_SPLIT    = 2
+	HINT_SOLUTION = 3
+)
+
+var (
+	ErrInvalidHintLevel = errors.New("hint level must be 1, 2 or 3")
+	ErrUnsolvable       = errors.New("puzzle has no solution")
+)
+
+// hintOperators are the binary operators in the order a level 1 hint
+// prefers them, the least obvious first
+var hintOperators = []string{POWER, DIVIDE, MULTIPLY, SUBTRACT, ADD}
+
+// Clue is what a hint reveals about one of the puzzle's solutions
+type Clue struct {
+	Level   int    `json:"level"`
+	Message string `json:"message"`
+	// Operator appears in the solution, at its top level from level 2 on
+	Operator string `json:"operator,omitempty"`
+	// Split is how many of the puzzle's digits go left of the top-level
+	// operator
+	Split    int    `json:"split,omitempty"`
+	Solution string `json:"solution,omitempty"`
+}
+
+// Hint suggests a way towards a solution of a puzzle under the default
+// spec. See (*Hectoc).Hint.
+func Hint(problem, partial string, level int) (*Clue, error) {
+	h, err := DefaultSpec.Puzzle(problem)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Hint(partial, level)
+}
+
+// Hint reveals more of a solution the higher the level: an operator it uses,
+// then where its top-level operator splits the digits, then the solution
+// itself. A solution that starts like the partial expression the player has
+// typed is preferred, so the hint builds on their work.
+func (h *Hectoc) Hint(partial string, level int) (*Clue, error) {
+	if level < HINT_OPERATOR || level > HINT_SOLUTION {
+		return nil, ErrInvalidHintLevel
+	}
+
+	solution, ok := h.hintSolution(partial)
+	if !ok {
+		return nil, ErrUnsolvable
+	}
+
+	e, err := Parse(solution)
+	if err != nil {
+		return nil, err
+	}
+
+	clue := &Clue{Level: level}
+
+	switch level {
+	case HINT_OPERATOR:
+		clue.Operator = hintOperator(e, partial)
+
+		if clue.Operator == "" {
+			clue.Message = fmt.Sprintf("Try using %s as a single number.", h.Problem)
+			break
+		}
+
+		clue.Message = fmt.Sprintf("Try using %s.", clue.Operator)
+
+	case HINT_SPLIT:
+		top := e
+		for top.Kind == UNARY_EXPR {
+			top = top.X
+		}
+
+		if top.Kind != BINARY_EXPR {
+			clue.Message = fmt.Sprintf("Try using %s as a single number.", h.Problem)
+			break
+		}
+
+		clue.Operator = top.Op
+		clue.Split = countDigits(top.X)
+		clue.Message = fmt.Sprintf("Try joining %s and %s with %s.", h.Problem[:clue.Split], h.Problem[clue.Split:], top.Op)
+
+	case HINT_SOLUTION:
+		clue.Solution = solution
+		clue.Message = fmt.Sprintf("One solution is %s.", solution)
+	}
+
+	return clue, nil
+}
+
+// hintSolution picks the first solution that continues the partial
+// expression, or the first solution if none does
+func (h *Hectoc) hintSolution(partial string) (string, bool) {
+	if len(h.Solutions) == 0 {
+		return "", false
+	}
+
+	partial = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, partial)
+
+	for _, solution := range h.Solutions {
+		if strings.HasPrefix(solution, partial) {
+			return solution, true
+		}
+	}
+
+	return h.Solutions[0], true
+}
+
+// hintOperator picks an operator of the expression, preferring one the
+// partial expression does not use yet
+func hintOperator(e *Expr, partial string) string {
+	used := ""
+
+	for _, op := range hintOperators {
+		if !e.uses(func(e *Expr) bool { return e.Kind == BINARY_EXPR && e.Op == op }) {
+			continue
+		}
+
+		if !strings.Contains(partial, op) {
+			return op
+		}
+
+		if used == "" {
+			used = op
+		}
+	}
+
+	return used
+}
+
+// countDigits counts the digits in the numbers of the expression
+func countDigits(e *Expr) int {
+	switch e.Kind {
+	case UNARY_EXPR:
+		return countDigits(e.X)
+	case BINARY_EXPR:
+		return countDigits(e.X) + countDigits(e.Y)
+	}
+
+	return len(strings.ReplaceAll(e.Value, ".", ""))
+}
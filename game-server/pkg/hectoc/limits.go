@@ -0,0 +1,91 @@
+package hectoc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Bounds on the work one expression may cause. Anything beyond them is
+// rejected with a *LimitError before it can tie up the evaluator.
+const (
+	MAX_EXPRESSION_LENGTH = 256
+	MAX_TOKENS            = 128
+	MAX_DEPTH             = 32
+	// MAX_MAGNITUDE_BITS bounds the numerator and denominator of every
+	// intermediate value
+	MAX_MAGNITUDE_BITS = 1024
+)
+
+// ErrTooComplex is what every *LimitError matches with errors.Is
+var ErrTooComplex = errors.New("expression too complex")
+
+// LimitKind names the limit an expression exceeded
+type LimitKind string
+
+// Limit kinds
+const (
+	LIMIT_LENGTH    LimitKind = "length"
+	LIMIT_TOKENS    LimitKind = "tokens"
+	LIMIT_DEPTH     LimitKind = "depth"
+	LIMIT_EXPONENT  LimitKind = "exponent"
+	LIMIT_MAGNITUDE LimitKind = "magnitude"
+)
+
+var limitErrorMessages = map[LimitKind]string{
+	LIMIT_LENGTH:    "longer than %d bytes",
+	LIMIT_TOKENS:    "more than %d tokens",
+	LIMIT_DEPTH:     "nested more than %d levels deep",
+	LIMIT_EXPONENT:  "exponent larger than %d",
+	LIMIT_MAGNITUDE: "value larger than %d bits",
+}
+
+var limitMaxima = map[LimitKind]int{
+	LIMIT_LENGTH:    MAX_EXPRESSION_LENGTH,
+	LIMIT_TOKENS:    MAX_TOKENS,
+	LIMIT_DEPTH:     MAX_DEPTH,
+	LIMIT_EXPONENT:  MAX_EXPONENT,
+	LIMIT_MAGNITUDE: MAX_MAGNITUDE_BITS,
+}
+
+// LimitError reports which limit an expression exceeded and where. Pos and
+// End are the byte offsets of the part that exceeded it.
+type LimitError struct {
+	Pos  int       `json:"pos"`
+	End  int       `json:"end"`
+	Kind LimitKind `json:"kind"`
+}
+
+func (e *LimitError) Error() string {
+	limit := fmt.Sprintf(limitErrorMessages[e.Kind], limitMaxima[e.Kind])
+
+	return fmt.Sprintf("%v: %s at position %d", ErrTooComplex, limit, e.Pos)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrTooComplex
+}
+
+// tooDeep returns the first node nested deeper than MAX_DEPTH, if any
+func tooDeep(e *Expr, level int) *Expr {
+	if level > MAX_DEPTH {
+		return e
+	}
+
+	switch e.Kind {
+	case UNARY_EXPR:
+		return tooDeep(e.X, level+1)
+	case BINARY_EXPR:
+		if deep := tooDeep(e.X, level+1); deep != nil {
+			return deep
+		}
+		return tooDeep(e.Y, level+1)
+	}
+
+	return nil
+}
+
+// tooLarge reports whether a value exceeds MAX_MAGNITUDE_BITS
+func tooLarge(x *big.Rat) bool {
+	return x.Num().BitLen() > MAX_MAGNITUDE_BITS || x.Denom().BitLen() > MAX_MAGNITUDE_BITS
+}
@@ -0,0 +1,76 @@
+package hectoc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	long := strings.Repeat("1", MAX_EXPRESSION_LENGTH+1)
+	tokens := strings.Repeat("1+", MAX_TOKENS/2) + "1"
+	parens := strings.Repeat("(", MAX_DEPTH+1) + "1" + strings.Repeat(")", MAX_DEPTH+1)
+	signs := strings.Repeat("-", MAX_DEPTH+1) + "1"
+	// The sixteenth factor of 2^64 takes the product past the limit
+	product := strings.Repeat("2^64*", 16) + "2"
+
+	tests := []struct {
+		name string
+		expr string
+		kind LimitKind
+		pos  int
+		end  int
+	}{
+		{"length", long, LIMIT_LENGTH, MAX_EXPRESSION_LENGTH, len(long)},
+		{"tokens", tokens, LIMIT_TOKENS, MAX_TOKENS, len(tokens)},
+		{"nested parentheses", parens, LIMIT_DEPTH, MAX_DEPTH, MAX_DEPTH + 1},
+		{"nested signs", signs, LIMIT_DEPTH, MAX_DEPTH + 1, len(signs)},
+		{"exponent", "2^100", LIMIT_EXPONENT, 2, 5},
+		{"negative exponent", "2^-65", LIMIT_EXPONENT, 2, 5},
+		{"computed exponent", "1+2^(3*30)", LIMIT_EXPONENT, 5, 9},
+		{"power tower", "2^3^4^5", LIMIT_EXPONENT, 4, 7},
+		{"large power", "(2^64)^17", LIMIT_MAGNITUDE, 1, 9},
+		{"large product", product, LIMIT_MAGNITUDE, 0, len(product) - len("*2")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.expr)
+			if err == nil {
+				_, err = e.Evaluate()
+			}
+
+			if !errors.Is(err, ErrTooComplex) {
+				t.Fatalf("got %v, want ErrTooComplex", err)
+			}
+
+			var le *LimitError
+			if !errors.As(err, &le) {
+				t.Fatalf("got %v, want a *LimitError", err)
+			}
+
+			if le.Kind != tt.kind || le.Pos != tt.pos || le.End != tt.end {
+				t.Fatalf("got %s at [%d, %d), want %s at [%d, %d)", le.Kind, le.Pos, le.End, tt.kind, tt.pos, tt.end)
+			}
+		})
+	}
+}
+
+func TestWithinLimits(t *testing.T) {
+	for _, expr := range []string{
+		strings.Repeat("1", MAX_EXPRESSION_LENGTH),
+		strings.Repeat("(", MAX_DEPTH) + "1" + strings.Repeat(")", MAX_DEPTH),
+		"2^64",
+		"2^-64",
+		"(2^64)^15",
+	} {
+		e, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", expr, err)
+		}
+
+		if _, err := e.Evaluate(); err != nil {
+			t.Fatalf("Evaluate(%q): %v", expr, err)
+		}
+	}
+}
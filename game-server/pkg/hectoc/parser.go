@@ -0,0 +1,545 @@
+package hectoc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ExprKind tells the nodes of an expression tree apart
+type ExprKind string
+
+// Expression kinds
+const (
+	NUMBER_EXPR ExprKind = "number"
+	UNARY_EXPR  ExprKind = "unary"
+	BINARY_EXPR ExprKind = "binary"
+)
+
+// Expr is a node of a parsed expression. Numbers keep their digits as
+// written; a unary operator (a sign, √ or the postfix !) has only X; a
+// binary operator has X and Y.
+// Pos and End are the byte offsets of the node's text in the source,
+// excluding any parentheses around it.
+type Expr struct {
+	Kind  ExprKind `json:"kind"`
+	Value string   `json:"value,omitempty"`
+	Op    string   `json:"op,omitempty"`
+	X     *Expr    `json:"x,omitempty"`
+	Y     *Expr    `json:"y,omitempty"`
+	Pos   int      `json:"pos"`
+	End   int      `json:"end"`
+}
+
+// ParseErrorKind classifies a malformed expression
+type ParseErrorKind string
+
+// Parse error kinds
+const (
+	PARSE_ERROR_EMPTY                ParseErrorKind = "empty"
+	PARSE_ERROR_UNEXPECTED_CHARACTER ParseErrorKind = "unexpected_character"
+	PARSE_ERROR_INVALID_NUMBER       ParseErrorKind = "invalid_number"
+	PARSE_ERROR_MISSING_OPERAND      ParseErrorKind = "missing_operand"
+	PARSE_ERROR_MISSING_OPERATOR     ParseErrorKind = "missing_operator"
+	PARSE_ERROR_UNCLOSED_PAREN       ParseErrorKind = "unclosed_paren"
+	PARSE_ERROR_UNMATCHED_PAREN      ParseErrorKind = "unmatched_paren"
+)
+
+var parseErrorMessages = map[ParseErrorKind]string{
+	PARSE_ERROR_EMPTY:                "empty expression",
+	PARSE_ERROR_UNEXPECTED_CHARACTER: "unexpected character",
+	PARSE_ERROR_INVALID_NUMBER:       "invalid number",
+	PARSE_ERROR_MISSING_OPERAND:      "missing operand",
+	PARSE_ERROR_MISSING_OPERATOR:     "missing operator",
+	PARSE_ERROR_UNCLOSED_PAREN:       "unclosed parenthesis",
+	PARSE_ERROR_UNMATCHED_PAREN:      "unmatched closing parenthesis",
+}
+
+// ParseError reports where and why an expression is malformed. Pos is the
+// byte offset of the offending text, which Near holds when there is any.
+type ParseError struct {
+	Pos  int            `json:"pos"`
+	Kind ParseErrorKind `json:"kind"`
+	Near string         `json:"near,omitempty"`
+}
+
+func (e *ParseError) Error() string {
+	if e.Near != "" {
+		return fmt.Sprintf("%s %q at position %d", parseErrorMessages[e.Kind], e.Near, e.Pos)
+	}
+
+	return fmt.Sprintf("%s at position %d", parseErrorMessages[e.Kind], e.Pos)
+}
+
+// Parse builds the expression tree of an expression. Malformed input yields
+// a *ParseError, input beyond the parsing limits a *LimitError.
+func Parse(expression string) (*Expr, error) {
+	if len(expression) > MAX_EXPRESSION_LENGTH {
+		return nil, &LimitError{Pos: MAX_EXPRESSION_LENGTH, End: len(expression), Kind: LIMIT_LENGTH}
+	}
+
+	c := newCalculator()
+
+	if err := c.tokenize(expression); err != nil {
+		return nil, err
+	}
+
+	if len(c.Tokens) > MAX_TOKENS {
+		return nil, &LimitError{Pos: c.Tokens[MAX_TOKENS].Pos, End: len(expression), Kind: LIMIT_TOKENS}
+	}
+
+	p := &parser{
+		tokens: c.Tokens,
+		end:    len(expression),
+	}
+
+	e, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if token, ok := p.peek(); ok {
+		if token.Type == RIGHT_PAREN {
+			return nil, &ParseError{Pos: token.Pos, Kind: PARSE_ERROR_UNMATCHED_PAREN, Near: token.Value}
+		}
+
+		return nil, &ParseError{Pos: token.Pos, Kind: PARSE_ERROR_MISSING_OPERATOR, Near: token.Value}
+	}
+
+	if deep := tooDeep(e, 0); deep != nil {
+		return nil, &LimitError{Pos: deep.Pos, End: deep.End, Kind: LIMIT_DEPTH}
+	}
+
+	return e, nil
+}
+
+// parser is a precedence climbing parser over the calculator's tokens
+type parser struct {
+	tokens []Token
+	next   int
+	end    int
+	depth  int
+}
+
+func (p *parser) peek() (Token, bool) {
+	if p.next >= len(p.tokens) {
+		return Token{}, false
+	}
+
+	return p.tokens[p.next], true
+}
+
+// binary parses a chain of binary operators binding at least as tightly as minPrec
+func (p *parser) binary(minPrec int) (*Expr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		token, ok := p.peek()
+		if !ok {
+			return left, nil
+		}
+
+		// An operand straight after another one lacks an operator
+		if token.Type == NUMBER || token.Type == LEFT_PAREN || token.Value == SQUARE_ROOT {
+			return nil, &ParseError{Pos: token.Pos, Kind: PARSE_ERROR_MISSING_OPERATOR, Near: token.Value}
+		}
+
+		prec, binary := precedence[token.Value]
+
+		if token.Type != OPERATOR || !binary || prec < minPrec {
+			return left, nil
+		}
+
+		p.next++
+
+		// Powers are right-associative, everything else left-associative
+		nextPrec := prec + 1
+		if token.Value == POWER {
+			nextPrec = prec
+		}
+
+		right, err := p.binary(nextPrec)
+		if err != nil {
+			return nil, err
+		}
+
+		left = &Expr{
+			Kind: BINARY_EXPR,
+			Op:   token.Value,
+			X:    left,
+			Y:    right,
+			Pos:  left.Pos,
+			End:  right.End,
+		}
+	}
+}
+
+// unary parses an operand with any leading signs. A sign binds more loosely
+// than a power, so -2^2 is -(2^2).
+func (p *parser) unary() (*Expr, error) {
+	token, ok := p.peek()
+
+	if ok && token.Type == OPERATOR && (token.Value == SUBTRACT || token.Value == ADD) {
+		p.next++
+
+		operand, err := p.binary(precedence[POWER])
+		if err != nil {
+			return nil, err
+		}
+
+		return &Expr{
+			Kind: UNARY_EXPR,
+			Op:   token.Value,
+			X:    operand,
+			Pos:  token.Pos,
+			End:  operand.End,
+		}, nil
+	}
+
+	return p.prefix()
+}
+
+// prefix parses an operand with any leading square roots. A root binds more
+// tightly than a power, so √4^3 is (√4)^3, but more loosely than a
+// factorial, so √4! is √(4!). A sign right after a root belongs to its
+// operand, so √-4 is √(-4).
+func (p *parser) prefix() (*Expr, error) {
+	token, ok := p.peek()
+
+	if ok && token.Type == OPERATOR && token.Value == SQUARE_ROOT {
+		p.next++
+
+		operand, err := p.rootOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		return &Expr{
+			Kind: UNARY_EXPR,
+			Op:   SQUARE_ROOT,
+			X:    operand,
+			Pos:  token.Pos,
+			End:  operand.End,
+		}, nil
+	}
+
+	return p.postfix()
+}
+
+// rootOperand parses the operand of a square root, which may be signed
+func (p *parser) rootOperand() (*Expr, error) {
+	token, ok := p.peek()
+
+	if !ok || token.Type != OPERATOR || (token.Value != SUBTRACT && token.Value != ADD) {
+		return p.prefix()
+	}
+
+	p.next++
+
+	operand, err := p.rootOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Expr{
+		Kind: UNARY_EXPR,
+		Op:   token.Value,
+		X:    operand,
+		Pos:  token.Pos,
+		End:  operand.End,
+	}, nil
+}
+
+// postfix parses an operand followed by any factorials
+func (p *parser) postfix() (*Expr, error) {
+	e, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		token, ok := p.peek()
+		if !ok || token.Type != OPERATOR || token.Value != FACTORIAL {
+			return e, nil
+		}
+
+		p.next++
+
+		e = &Expr{
+			Kind: UNARY_EXPR,
+			Op:   FACTORIAL,
+			X:    e,
+			Pos:  e.Pos,
+			End:  token.Pos + len(token.Value),
+		}
+	}
+}
+
+// primary parses a number or a parenthesised expression
+func (p *parser) primary() (*Expr, error) {
+	token, ok := p.peek()
+
+	if !ok {
+		return nil, &ParseError{Pos: p.end, Kind: PARSE_ERROR_MISSING_OPERAND}
+	}
+
+	switch token.Type {
+	case NUMBER:
+		p.next++
+
+		if _, ok := new(big.Rat).SetString(token.Value); !ok {
+			return nil, &ParseError{Pos: token.Pos, Kind: PARSE_ERROR_INVALID_NUMBER, Near: token.Value}
+		}
+
+		return &Expr{
+			Kind:  NUMBER_EXPR,
+			Value: token.Value,
+			Pos:   token.Pos,
+			End:   token.Pos + len(token.Value),
+		}, nil
+
+	case LEFT_PAREN:
+		p.next++
+
+		p.depth++
+		defer func() { p.depth-- }()
+
+		if p.depth > MAX_DEPTH {
+			return nil, &LimitError{Pos: token.Pos, End: token.Pos + len(token.Value), Kind: LIMIT_DEPTH}
+		}
+
+		e, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+
+		closing, ok := p.peek()
+		if !ok || closing.Type != RIGHT_PAREN {
+			return nil, &ParseError{Pos: token.Pos, Kind: PARSE_ERROR_UNCLOSED_PAREN, Near: token.Value}
+		}
+
+		p.next++
+
+		return e, nil
+	}
+
+	return nil, &ParseError{Pos: token.Pos, Kind: PARSE_ERROR_MISSING_OPERAND, Near: token.Value}
+}
+
+// Evaluate computes the exact value of the expression. An exponent or an
+// intermediate value beyond the evaluation limits yields a *LimitError.
+func (e *Expr) Evaluate() (*big.Rat, error) {
+	return e.reduce(nil)
+}
+
+// reduce evaluates the expression bottom-up and left to right, handing every
+// operator it applies to step, if any, along with its operands and result
+func (e *Expr) reduce(step func(e *Expr, operands []*big.Rat, result *big.Rat)) (*big.Rat, error) {
+	if e.Kind == NUMBER_EXPR {
+		num, ok := new(big.Rat).SetString(e.Value)
+		if !ok {
+			return nil, fmt.Errorf("invalid number: %s", e.Value)
+		}
+		return num, nil
+	}
+
+	x, err := e.X.reduce(step)
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []*big.Rat{x}
+
+	if e.Kind == BINARY_EXPR {
+		y, err := e.Y.reduce(step)
+		if err != nil {
+			return nil, err
+		}
+
+		operands = append(operands, y)
+	}
+
+	result, err := e.apply(operands)
+	if err != nil {
+		return nil, err
+	}
+
+	if tooLarge(result) {
+		return nil, &LimitError{Pos: e.Pos, End: e.End, Kind: LIMIT_MAGNITUDE}
+	}
+
+	if step != nil {
+		step(e, operands, result)
+	}
+
+	return result, nil
+}
+
+// apply computes the node's operator on the values of its operands, leaving
+// them untouched
+func (e *Expr) apply(operands []*big.Rat) (*big.Rat, error) {
+	a := operands[0]
+
+	if e.Kind == UNARY_EXPR {
+		switch e.Op {
+		case SUBTRACT:
+			return new(big.Rat).Neg(a), nil
+		case FACTORIAL:
+			return ratFactorial(a)
+		case SQUARE_ROOT:
+			return ratSqrt(a)
+		}
+		return a, nil
+	}
+
+	b := operands[1]
+	result := new(big.Rat)
+
+	switch e.Op {
+	case ADD:
+		result.Add(a, b)
+	case SUBTRACT:
+		result.Sub(a, b)
+	case MULTIPLY:
+		result.Mul(a, b)
+	case DIVIDE:
+		if b.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		result.Quo(a, b)
+	case POWER:
+		result, err := ratPow(a, b)
+
+		switch {
+		case errors.Is(err, ErrExponentTooLarge):
+			return nil, &LimitError{Pos: e.Y.Pos, End: e.Y.End, Kind: LIMIT_EXPONENT}
+		case errors.Is(err, ErrPowerTooLarge):
+			return nil, &LimitError{Pos: e.Pos, End: e.End, Kind: LIMIT_MAGNITUDE}
+		}
+
+		return result, err
+	default:
+		return nil, fmt.Errorf("unsupported operator: %s", e.Op)
+	}
+
+	return result, nil
+}
+
+// String renders the expression with only the parentheses it needs
+func (e *Expr) String() string {
+	var sb strings.Builder
+	e.write(&sb)
+	return sb.String()
+}
+
+// Binding strengths of the unary operators and numbers when rendering,
+// above those of the binary operators. A sign binds between products and
+// powers.
+const (
+	signPrec       = 2
+	squareRootPrec = 4
+	factorialPrec  = 5
+	numberPrec     = 5
+)
+
+// prec is the binding strength of a node when rendering
+func (e *Expr) prec() int {
+	switch e.Kind {
+	case BINARY_EXPR:
+		return precedence[e.Op]
+	case UNARY_EXPR:
+		switch e.Op {
+		case FACTORIAL:
+			return factorialPrec
+		case SQUARE_ROOT:
+			return squareRootPrec
+		}
+		return signPrec
+	}
+
+	return numberPrec
+}
+
+func (e *Expr) write(sb *strings.Builder) {
+	xParens, yParens := e.operandParens()
+
+	switch e.Kind {
+	case NUMBER_EXPR:
+		sb.WriteString(e.Value)
+
+	case UNARY_EXPR:
+		if e.Op == FACTORIAL {
+			writeOperand(sb, e.X, xParens)
+			sb.WriteString(e.Op)
+			break
+		}
+
+		sb.WriteString(e.Op)
+		writeOperand(sb, e.X, xParens)
+
+	case BINARY_EXPR:
+		writeOperand(sb, e.X, xParens)
+		sb.WriteString(e.Op)
+		writeOperand(sb, e.Y, yParens)
+	}
+}
+
+// operandParens reports which operands of the node need parentheses when
+// written out
+func (e *Expr) operandParens() (x, y bool) {
+	switch e.Kind {
+	case UNARY_EXPR:
+		switch e.Op {
+		case FACTORIAL:
+			return e.X.prec() < factorialPrec, false
+		case SQUARE_ROOT:
+			return e.X.prec() < squareRootPrec, false
+		}
+		return e.X.prec() < precedence[POWER], false
+
+	case BINARY_EXPR:
+		prec := e.prec()
+
+		// Powers group to the right, everything else to the left
+		x = e.X.prec() < prec || e.Op == POWER && e.X.prec() <= prec
+		y = e.Y.prec() < prec || e.Op != POWER && e.Y.prec() == prec && (e.Op == SUBTRACT || e.Op == DIVIDE)
+
+		// A right operand that starts with a sign needs no parentheses to
+		// parse, but they keep the rendering readable
+		if e.Y.leadingSign() {
+			y = true
+		}
+	}
+
+	return x, y
+}
+
+// leadingSign reports whether the expression starts with a sign when
+// written out
+func (e *Expr) leadingSign() bool {
+	switch e.Kind {
+	case UNARY_EXPR:
+		return e.Op == SUBTRACT || e.Op == ADD
+	case BINARY_EXPR:
+		x, _ := e.operandParens()
+		return !x && e.X.leadingSign()
+	}
+
+	return false
+}
+
+func writeOperand(sb *strings.Builder, e *Expr, parens bool) {
+	if parens {
+		sb.WriteString("(")
+	}
+
+	e.write(sb)
+
+	if parens {
+		sb.WriteString(")")
+	}
+}
@@ -0,0 +1,42 @@
+package hectoc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseErrorPositions(t *testing.T) {
+	tests := []struct {
+		expr string
+		kind ParseErrorKind
+		pos  int
+	}{
+		{"", PARSE_ERROR_EMPTY, 0},
+		{"1$2", PARSE_ERROR_UNEXPECTED_CHARACTER, 1},
+		{"1+", PARSE_ERROR_MISSING_OPERAND, 2},
+		{"1+*2", PARSE_ERROR_MISSING_OPERAND, 2},
+		{"√-", PARSE_ERROR_MISSING_OPERAND, 4},
+		{"()", PARSE_ERROR_MISSING_OPERAND, 1},
+		{"1 2", PARSE_ERROR_MISSING_OPERATOR, 2},
+		{"(1+2)3", PARSE_ERROR_MISSING_OPERATOR, 5},
+		{"1..2", PARSE_ERROR_MISSING_OPERATOR, 2},
+		{"(1+2", PARSE_ERROR_UNCLOSED_PAREN, 0},
+		{"1*((2+3)", PARSE_ERROR_UNCLOSED_PAREN, 2},
+		{"1+2)", PARSE_ERROR_UNMATCHED_PAREN, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+
+			var pe *ParseError
+			if !errors.As(err, &pe) {
+				t.Fatalf("got error %v, want a *ParseError", err)
+			}
+
+			if pe.Kind != tt.kind || pe.Pos != tt.pos {
+				t.Fatalf("got %s at %d, want %s at %d", pe.Kind, pe.Pos, tt.kind, tt.pos)
+			}
+		})
+	}
+}
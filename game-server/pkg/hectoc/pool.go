@@ -0,0 +1,493 @@
+package hectoc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// POOL_RETRY_DELAY is how long a worker waits before retrying a key whose
+// puzzle could not be generated, e.g. because its difficulty band is empty.
+// The delay doubles with every failure in a row, up to POOL_MAX_RETRY_DELAY.
+const (
+	POOL_RETRY_DELAY     = 5 * time.Second
+	POOL_MAX_RETRY_DELAY = 5 * time.Minute
+)
+
+// POOL_MAX_FAILURES is how many failures in a row a key may have before the
+// pool gives up on it. The keys a pool was created with are retried for
+// good.
+const POOL_MAX_FAILURES = 3
+
+// DEFAULT_POOL_MAX_KEYS and DEFAULT_POOL_IDLE_TIMEOUT bound the keys a pool
+// keeps queues for, unless its config says otherwise
+const (
+	DEFAULT_POOL_MAX_KEYS     = 32
+	DEFAULT_POOL_IDLE_TIMEOUT = 10 * time.Minute
+)
+
+var (
+	ErrInvalidPoolKey = errors.New("invalid pool key")
+	ErrPoolFull       = errors.New("puzzle pool has no room for another key")
+)
+
+// PoolKey identifies one stream of puzzles: a spec and a difficulty band
+type PoolKey struct {
+	Spec          Spec `json:"spec"`
+	MinDifficulty int  `json:"minDifficulty"`
+	MaxDifficulty int  `json:"maxDifficulty"`
+}
+
+// DefaultPoolKey is classic Hectoc at any difficulty
+var DefaultPoolKey = PoolKey{
+	Spec:          DefaultSpec,
+	MinDifficulty: 0,
+	MaxDifficulty: MAX_DIFFICULTY,
+}
+
+// Validate checks that the key names a valid spec and either the whole
+// difficulty scale or one of the DifficultyBands, so the number of keys a
+// spec can spread over stays small
+func (k PoolKey) Validate() error {
+	if err := k.Spec.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPoolKey, err)
+	}
+
+	if k.MinDifficulty == 0 && k.MaxDifficulty == MAX_DIFFICULTY {
+		return nil
+	}
+
+	for _, band := range DifficultyBands {
+		if k.MinDifficulty == band[0] && k.MaxDifficulty == band[1] {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: difficulty must be a named band", ErrInvalidPoolKey)
+}
+
+// generate produces one puzzle for the key
+func (k PoolKey) generate() (*Hectoc, error) {
+	if k.MinDifficulty <= 0 && k.MaxDifficulty >= MAX_DIFFICULTY {
+		return k.Spec.Generate()
+	}
+
+	return k.Spec.GenerateWithDifficulty(k.MinDifficulty, k.MaxDifficulty)
+}
+
+// PoolConfig sizes a Pool
+type PoolConfig struct {
+	// Workers is the number of goroutines generating puzzles
+	Workers int
+	// Buffer is the number of ready puzzles kept per key
+	Buffer int
+	// MaxKeys is the most keys the pool keeps queues for at once, including
+	// the ones it was created with
+	MaxKeys int
+	// IdleTimeout is how long a key nobody asks for keeps its queue. The
+	// keys the pool was created with are never dropped.
+	IdleTimeout time.Duration
+}
+
+// PoolMetrics is a snapshot of a pool's counters
+type PoolMetrics struct {
+	Generated uint64          `json:"generated"`
+	Served    uint64          `json:"served"`
+	Misses    uint64          `json:"misses"`
+	Failures  uint64          `json:"failures"`
+	Rejected  uint64          `json:"rejected"`
+	Evicted   uint64          `json:"evicted"`
+	Ready     []PoolKeyMetric `json:"ready"`
+}
+
+// PoolKeyMetric is the number of ready puzzles for one key
+type PoolKeyMetric struct {
+	Key    PoolKey `json:"key"`
+	Ready  int     `json:"ready"`
+	Failed bool    `json:"failed"`
+}
+
+// poolQueue is the buffer of one key and the bookkeeping that bounds the
+// work spent on it
+type poolQueue struct {
+	ready chan *Hectoc
+	// done is closed when the pool gives up on the key or drops it
+	done chan struct{}
+	// pending is the number of jobs queued or running for the key
+	pending  int
+	failures int
+	failed   bool
+	pinned   bool
+	lastUsed time.Time
+}
+
+// Pool generates and solves puzzles on background goroutines and keeps a
+// buffer of them ready per key, so callers never wait on the solver. The
+// work it takes on is bounded: at most Buffer jobs per key and MaxKeys keys,
+// with idle and failing keys dropped.
+type Pool struct {
+	config PoolConfig
+
+	mu     sync.Mutex
+	queues map[PoolKey]*poolQueue
+	// pinned holds the jobs of the keys the pool was created with, which
+	// are worked on before any other so the keys asked for at will cannot
+	// starve them
+	pinned []PoolKey
+	jobs   []PoolKey
+
+	wake chan struct{}
+	quit chan struct{}
+	wg   sync.WaitGroup
+
+	generated atomic.Uint64
+	served    atomic.Uint64
+	misses    atomic.Uint64
+	failures  atomic.Uint64
+	rejected  atomic.Uint64
+	evicted   atomic.Uint64
+}
+
+// NewPool creates a pool that pre-generates puzzles for the given keys once
+// started. Other keys are added the first time they are asked for, as long
+// as they are valid and there is room for them.
+func NewPool(config PoolConfig, keys ...PoolKey) *Pool {
+	if config.Workers < 1 {
+		config.Workers = 1
+	}
+
+	if config.Buffer < 1 {
+		config.Buffer = 1
+	}
+
+	if config.MaxKeys < 1 {
+		config.MaxKeys = DEFAULT_POOL_MAX_KEYS
+	}
+
+	if config.MaxKeys < len(keys) {
+		config.MaxKeys = len(keys)
+	}
+
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = DEFAULT_POOL_IDLE_TIMEOUT
+	}
+
+	p := &Pool{
+		config: config,
+		queues: make(map[PoolKey]*poolQueue),
+		wake:   make(chan struct{}, config.Workers),
+		quit:   make(chan struct{}),
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, key := range keys {
+		q := p.add(key)
+		q.pinned = true
+		p.fill(key, q)
+	}
+
+	return p
+}
+
+// Start launches the worker goroutines
+func (p *Pool) Start() {
+	for i := 0; i < p.config.Workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+}
+
+// Stop shuts the workers down and waits for them to finish
+func (p *Pool) Stop() {
+	close(p.quit)
+	p.wg.Wait()
+}
+
+// TryGet returns a ready puzzle for the key without waiting. On a miss the
+// key is queued for generation, if the pool takes it, and false is
+// returned.
+func (p *Pool) TryGet(key PoolKey) (*Hectoc, bool) {
+	q, err := p.queue(key)
+
+	if err != nil {
+		p.misses.Add(1)
+		return nil, false
+	}
+
+	select {
+	case h := <-q.ready:
+		p.served.Add(1)
+		p.reschedule(key)
+		return h, true
+	default:
+		p.misses.Add(1)
+		return nil, false
+	}
+}
+
+// Get waits until a puzzle for the key is ready or the context is done. It
+// fails at once if the key is invalid or the pool has no room for it, and
+// with ErrNoPuzzle once the pool gives up on the key.
+func (p *Pool) Get(ctx context.Context, key PoolKey) (*Hectoc, error) {
+	q, err := p.queue(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case h := <-q.ready:
+		p.served.Add(1)
+		p.reschedule(key)
+		return h, nil
+	case <-q.done:
+		return nil, ErrNoPuzzle
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Metrics returns a snapshot of the pool's counters
+func (p *Pool) Metrics() PoolMetrics {
+	m := PoolMetrics{
+		Generated: p.generated.Load(),
+		Served:    p.served.Load(),
+		Misses:    p.misses.Load(),
+		Failures:  p.failures.Load(),
+		Rejected:  p.rejected.Load(),
+		Evicted:   p.evicted.Load(),
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, q := range p.queues {
+		m.Ready = append(m.Ready, PoolKeyMetric{Key: key, Ready: len(q.ready), Failed: q.failed})
+	}
+
+	return m
+}
+
+// queue returns the buffer for a key, creating it and scheduling enough
+// jobs to fill it the first time the key is seen. Invalid keys, and new
+// keys once the pool holds MaxKeys that are all in use, are turned away.
+func (p *Pool) queue(key PoolKey) (*poolQueue, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if q, ok := p.queues[key]; ok {
+		q.lastUsed = time.Now()
+
+		if q.failed {
+			return nil, ErrNoPuzzle
+		}
+
+		return q, nil
+	}
+
+	if err := key.Validate(); err != nil {
+		p.rejected.Add(1)
+		return nil, err
+	}
+
+	p.evictIdle()
+
+	if len(p.queues) >= p.config.MaxKeys {
+		p.rejected.Add(1)
+		return nil, ErrPoolFull
+	}
+
+	q := p.add(key)
+	p.fill(key, q)
+
+	return q, nil
+}
+
+// add creates the queue for a key. The caller holds p.mu.
+func (p *Pool) add(key PoolKey) *poolQueue {
+	q := &poolQueue{
+		ready:    make(chan *Hectoc, p.config.Buffer),
+		done:     make(chan struct{}),
+		lastUsed: time.Now(),
+	}
+
+	p.queues[key] = q
+
+	return q
+}
+
+// evictIdle drops the queues of keys nobody asked for in a while, along
+// with keys the pool gave up on. The caller holds p.mu.
+func (p *Pool) evictIdle() {
+	for key, q := range p.queues {
+		if !q.pinned && time.Since(q.lastUsed) > p.config.IdleTimeout {
+			p.drop(key, q)
+			p.evicted.Add(1)
+		}
+	}
+}
+
+// drop forgets a key along with the jobs still queued for it. The caller
+// holds p.mu.
+func (p *Pool) drop(key PoolKey, q *poolQueue) {
+	delete(p.queues, key)
+
+	jobs := p.jobs[:0]
+
+	for _, job := range p.jobs {
+		if job != key {
+			jobs = append(jobs, job)
+		}
+	}
+
+	p.jobs = jobs
+
+	if !q.failed {
+		close(q.done)
+	}
+}
+
+// fill schedules jobs until every free slot in the key's buffer has one.
+// The caller holds p.mu.
+func (p *Pool) fill(key PoolKey, q *poolQueue) {
+	for !q.failed && len(q.ready)+q.pending < p.config.Buffer {
+		q.pending++
+		p.push(key, q)
+	}
+}
+
+// push queues a job for a key and wakes a worker. The caller holds p.mu.
+func (p *Pool) push(key PoolKey, q *poolQueue) {
+	if q.pinned {
+		p.pinned = append(p.pinned, key)
+	} else {
+		p.jobs = append(p.jobs, key)
+	}
+
+	select {
+	case p.wake <- struct{}{}:
+	default:
+		// Every worker has a wake-up coming already
+	}
+}
+
+// reschedule replaces a puzzle taken from the key's buffer
+func (p *Pool) reschedule(key PoolKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if q, ok := p.queues[key]; ok {
+		p.fill(key, q)
+	}
+}
+
+// next waits for a job, taking pinned keys first, and returns it with the
+// queue it is for
+func (p *Pool) next() (PoolKey, *poolQueue, bool) {
+	for {
+		p.mu.Lock()
+
+		var key PoolKey
+
+		switch {
+		case len(p.pinned) > 0:
+			key, p.pinned = p.pinned[0], p.pinned[1:]
+		case len(p.jobs) > 0:
+			key, p.jobs = p.jobs[0], p.jobs[1:]
+		default:
+			p.mu.Unlock()
+
+			select {
+			case <-p.quit:
+				return PoolKey{}, nil, false
+			case <-p.wake:
+			}
+
+			continue
+		}
+
+		q := p.queues[key]
+		p.mu.Unlock()
+
+		return key, q, true
+	}
+}
+
+// finish records the outcome of a job for a key, unless the key was
+// dropped meanwhile
+func (p *Pool) finish(key PoolKey, q *poolQueue, h *Hectoc, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.queues[key] != q || q.failed {
+		return
+	}
+
+	if err == nil {
+		q.pending--
+		q.failures = 0
+
+		select {
+		case q.ready <- h:
+		default:
+			// The buffer is already full
+		}
+
+		return
+	}
+
+	q.failures++
+
+	if q.failures >= POOL_MAX_FAILURES && !q.pinned {
+		// Keep the key around as failed, so asking for it again fails
+		// fast, until it goes idle
+		q.failed = true
+		q.pending = 0
+		close(q.done)
+		return
+	}
+
+	// Retry later, backing off with every failure in a row; the job stays
+	// pending meanwhile so the key gets no more than its share
+	delay := POOL_MAX_RETRY_DELAY
+
+	if q.failures < 8 {
+		delay = min(POOL_RETRY_DELAY<<(q.failures-1), POOL_MAX_RETRY_DELAY)
+	}
+
+	time.AfterFunc(delay, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		if p.queues[key] == q && !q.failed {
+			p.push(key, q)
+		}
+	})
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for {
+		key, q, ok := p.next()
+
+		if !ok {
+			return
+		}
+
+		h, err := key.generate()
+
+		if err != nil {
+			p.failures.Add(1)
+		} else {
+			p.generated.Add(1)
+		}
+
+		p.finish(key, q, h, err)
+	}
+}
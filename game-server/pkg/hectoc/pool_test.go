@@ -0,0 +1,115 @@
+package hectoc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// unsolvableKey is a valid key no puzzle can be generated for: a single 1
+// never reaches 100
+var unsolvableKey = PoolKey{
+	Spec:          Spec{Digits: 1, Target: 100, Alphabet: "1", Rules: OfficialRules},
+	MinDifficulty: 0,
+	MaxDifficulty: MAX_DIFFICULTY,
+}
+
+func TestPoolServesPuzzles(t *testing.T) {
+	p := NewPool(PoolConfig{Workers: 2, Buffer: 2}, DefaultPoolKey)
+	p.Start()
+	defer p.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		h, err := p.Get(ctx, DefaultPoolKey)
+
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+
+		if !DefaultSpec.Matches(h.Problem) || len(h.Solutions) == 0 {
+			t.Fatalf("got puzzle %+v, want a solvable classic puzzle", h)
+		}
+	}
+}
+
+func TestPoolRejectsKeys(t *testing.T) {
+	p := NewPool(PoolConfig{Workers: 1, Buffer: 1, MaxKeys: 2}, DefaultPoolKey)
+	defer p.Stop()
+
+	band := DefaultPoolKey
+	band.MinDifficulty, band.MaxDifficulty = 1, 2
+
+	if _, err := p.Get(context.Background(), band); !errors.Is(err, ErrInvalidPoolKey) {
+		t.Fatalf("unnamed band: got %v, want ErrInvalidPoolKey", err)
+	}
+
+	easy := DefaultPoolKey
+	easy.MinDifficulty, easy.MaxDifficulty = DifficultyBands["easy"][0], DifficultyBands["easy"][1]
+
+	if _, ok := p.TryGet(easy); ok {
+		t.Fatal("got a puzzle from a pool that was never started")
+	}
+
+	hard := DefaultPoolKey
+	hard.MinDifficulty, hard.MaxDifficulty = DifficultyBands["hard"][0], DifficultyBands["hard"][1]
+
+	if _, err := p.Get(context.Background(), hard); !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("key over the limit: got %v, want ErrPoolFull", err)
+	}
+
+	if m := p.Metrics(); m.Rejected != 2 || len(m.Ready) != 2 {
+		t.Fatalf("got metrics %+v, want 2 rejected and 2 keys", m)
+	}
+}
+
+func TestPoolEvictsIdleKeys(t *testing.T) {
+	p := NewPool(PoolConfig{Workers: 1, Buffer: 1, MaxKeys: 2, IdleTimeout: time.Millisecond}, DefaultPoolKey)
+	defer p.Stop()
+
+	easy := DefaultPoolKey
+	easy.MinDifficulty, easy.MaxDifficulty = DifficultyBands["easy"][0], DifficultyBands["easy"][1]
+	p.TryGet(easy)
+
+	time.Sleep(10 * time.Millisecond)
+
+	hard := DefaultPoolKey
+	hard.MinDifficulty, hard.MaxDifficulty = DifficultyBands["hard"][0], DifficultyBands["hard"][1]
+
+	if _, ok := p.TryGet(hard); ok {
+		t.Fatal("got a puzzle from a pool that was never started")
+	}
+
+	if m := p.Metrics(); m.Evicted != 1 || m.Rejected != 0 {
+		t.Fatalf("got metrics %+v, want the idle key evicted for the new one", m)
+	}
+}
+
+func TestPoolGivesUpOnFailingKeys(t *testing.T) {
+	p := NewPool(PoolConfig{Workers: 2, Buffer: POOL_MAX_FAILURES})
+	p.Start()
+	defer p.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := p.Get(ctx, unsolvableKey); !errors.Is(err, ErrNoPuzzle) {
+		t.Fatalf("got %v, want ErrNoPuzzle", err)
+	}
+
+	// Asking again fails at once, without another round of work
+	failures := p.Metrics().Failures
+
+	if _, err := p.Get(ctx, unsolvableKey); !errors.Is(err, ErrNoPuzzle) {
+		t.Fatalf("got %v, want ErrNoPuzzle", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if m := p.Metrics(); m.Failures != failures {
+		t.Fatalf("key kept being worked on after the pool gave up: %d failures, then %d", failures, m.Failures)
+	}
+}
@@ -0,0 +1,185 @@
+package hectoc
+
+import (
+	"errors"
+	"math/big"
+)
+
+// Bounds for the power and factorial operators. Operands outside these
+// limits are rejected instead of being evaluated, since the exact result can
+// grow without bound.
+const (
+	MAX_EXPONENT    = 64
+	MAX_ROOT_DEGREE = 16
+	MAX_FACTORIAL   = 20
+)
+
+var (
+	ErrExponentTooLarge = errors.New("exponent too large")
+	ErrPowerTooLarge    = errors.New("power too large")
+	ErrInexactPower     = errors.New("power does not have an exact rational result")
+	ErrDivisionByZero   = errors.New("division by zero")
+	ErrInvalidFactorial = errors.New("factorial is only defined for whole numbers from 0 to 20")
+	ErrInexactRoot      = errors.New("square root does not have an exact rational result")
+	ErrNegativeRoot     = errors.New("square root of a negative number")
+)
+
+// ratPow raises base to exp exactly. Integer exponents are computed directly,
+// fractional exponents p/q only when the q-th root of base is itself rational.
+func ratPow(base, exp *big.Rat) (*big.Rat, error) {
+	if exp.IsInt() {
+		return ratPowInt(base, exp.Num())
+	}
+
+	// Every root of 0 and 1 is rational, and -1 has odd roots only
+	if isUnit(base) {
+		if base.Sign() < 0 && exp.Denom().Bit(0) == 0 {
+			return nil, ErrInexactPower
+		}
+		return unitPow(base, exp.Num())
+	}
+
+	q := exp.Denom()
+	if !q.IsInt64() || q.Int64() > MAX_ROOT_DEGREE {
+		return nil, ErrExponentTooLarge
+	}
+
+	root, ok := ratRoot(base, int(q.Int64()))
+	if !ok {
+		return nil, ErrInexactPower
+	}
+
+	return ratPowInt(root, exp.Num())
+}
+
+// ratPowInt raises base to the integer power e
+func ratPowInt(base *big.Rat, e *big.Int) (*big.Rat, error) {
+	// Powers of 0, 1 and -1 stay small however large the exponent is
+	if isUnit(base) {
+		return unitPow(base, e)
+	}
+
+	if !e.IsInt64() || e.Int64() > MAX_EXPONENT || e.Int64() < -MAX_EXPONENT {
+		return nil, ErrExponentTooLarge
+	}
+
+	n := e.Int64()
+	if n < 0 {
+		if base.Sign() == 0 {
+			return nil, ErrDivisionByZero
+		}
+		n = -n
+	}
+
+	// Refuse powers whose result would be too large before computing them
+	bits := max(base.Num().BitLen(), base.Denom().BitLen())
+	if int64(bits-1)*n > MAX_MAGNITUDE_BITS {
+		return nil, ErrPowerTooLarge
+	}
+
+	k := big.NewInt(n)
+	num := new(big.Int).Exp(base.Num(), k, nil)
+	den := new(big.Int).Exp(base.Denom(), k, nil)
+
+	if e.Sign() < 0 {
+		num, den = den, num
+	}
+
+	return new(big.Rat).SetFrac(num, den), nil
+}
+
+// isUnit reports whether x is 0, 1 or -1
+func isUnit(x *big.Rat) bool {
+	return x.IsInt() && x.Num().IsInt64() && x.Num().Int64() >= -1 && x.Num().Int64() <= 1
+}
+
+// unitPow raises 0, 1 or -1 to the integer power e without computing it
+func unitPow(base *big.Rat, e *big.Int) (*big.Rat, error) {
+	switch {
+	case base.Sign() == 0 && e.Sign() < 0:
+		return nil, ErrDivisionByZero
+	case base.Sign() == 0 && e.Sign() > 0:
+		return new(big.Rat), nil
+	case base.Sign() < 0 && e.Bit(0) == 1:
+		return big.NewRat(-1, 1), nil
+	}
+
+	return big.NewRat(1, 1), nil
+}
+
+// ratRoot returns the exact k-th root of x, if it exists
+func ratRoot(x *big.Rat, k int) (*big.Rat, bool) {
+	if x.Sign() < 0 && k%2 == 0 {
+		return nil, false
+	}
+
+	num, ok := intRoot(new(big.Int).Abs(x.Num()), k)
+	if !ok {
+		return nil, false
+	}
+
+	den, ok := intRoot(x.Denom(), k)
+	if !ok {
+		return nil, false
+	}
+
+	if x.Sign() < 0 {
+		num.Neg(num)
+	}
+
+	return new(big.Rat).SetFrac(num, den), true
+}
+
+// intRoot returns the exact k-th root of a non-negative integer, if it exists
+func intRoot(n *big.Int, k int) (*big.Int, bool) {
+	if n.Sign() == 0 || k == 1 {
+		return new(big.Int).Set(n), true
+	}
+
+	// Binary search between 0 and 2^(bitlen/k + 1)
+	lo := big.NewInt(0)
+	hi := new(big.Int).Lsh(big.NewInt(1), uint(n.BitLen()/k+1))
+	exp := big.NewInt(int64(k))
+	one := big.NewInt(1)
+
+	for lo.Cmp(hi) <= 0 {
+		mid := new(big.Int).Add(lo, hi)
+		mid.Rsh(mid, 1)
+
+		switch new(big.Int).Exp(mid, exp, nil).Cmp(n) {
+		case 0:
+			return mid, true
+		case -1:
+			lo.Add(mid, one)
+		default:
+			hi.Sub(mid, one)
+		}
+	}
+
+	return nil, false
+}
+
+// ratFactorial returns n! for a whole number n up to MAX_FACTORIAL
+func ratFactorial(n *big.Rat) (*big.Rat, error) {
+	if !n.IsInt() || n.Sign() < 0 || n.Num().Cmp(big.NewInt(MAX_FACTORIAL)) > 0 {
+		return nil, ErrInvalidFactorial
+	}
+
+	result := new(big.Int).MulRange(1, n.Num().Int64())
+
+	return new(big.Rat).SetInt(result), nil
+}
+
+// ratSqrt returns the square root of x if it is rational
+func ratSqrt(x *big.Rat) (*big.Rat, error) {
+	if x.Sign() < 0 {
+		return nil, ErrNegativeRoot
+	}
+
+	root, ok := ratRoot(x, 2)
+	if !ok {
+		return nil, ErrInexactRoot
+	}
+
+	return root, nil
+}
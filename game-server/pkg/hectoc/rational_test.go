@@ -0,0 +1,146 @@
+package hectoc
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// evaluateTest is an expression and either its exact value or the error
+// evaluating it fails with
+type evaluateTest struct {
+	expr string
+	want string
+	err  error
+}
+
+func runEvaluateTests(t *testing.T, tests []evaluateTest) {
+	t.Helper()
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			got, err := e.Evaluate()
+
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("got %v, %v; want error %v", got, err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Evaluate: %v", err)
+			}
+
+			want, _ := new(big.Rat).SetString(tt.want)
+			if got.Cmp(want) != 0 {
+				t.Fatalf("got %v, want %v", got.RatString(), tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateIsExact(t *testing.T) {
+	runEvaluateTests(t, []evaluateTest{
+		{"1/3+1/3+1/3", "1", nil},
+		{"(1/3)*300", "100", nil},
+		{"1/7*7*100", "100", nil},
+		{"0.1+0.2", "3/10", nil},
+		{"2/4", "1/2", nil},
+		{"2^-2", "1/4", nil},
+		{"2^3^2", "512", nil},
+		{"-2^2", "-4", nil},
+		{"4^(1/2)", "2", nil},
+		{"8^(2/3)", "4", nil},
+		{"(0-8)^(1/3)", "-2", nil},
+		{"0^0", "1", nil},
+		{"1^23456", "1", nil},
+		{"1^-23456", "1", nil},
+		{"(-1)^23456", "1", nil},
+		{"(-1)^23457", "-1", nil},
+		{"0^23456", "0", nil},
+		{"1^(1/100)", "1", nil},
+		{"(-1)^(2/99)", "1", nil},
+		{"(-1)^(1/99)", "-1", nil},
+		{"(-1)^(1/100)", "", ErrInexactPower},
+		{"0^-23456", "", ErrDivisionByZero},
+		{"2^(1/2)", "", ErrInexactPower},
+		{"1/0", "", ErrDivisionByZero},
+		{"1/(2-2)", "", ErrDivisionByZero},
+		{"0^-1", "", ErrDivisionByZero},
+		{"0^(0-1)", "", ErrDivisionByZero},
+		{"2^100", "", ErrTooComplex},
+	})
+}
+
+func TestFactorial(t *testing.T) {
+	runEvaluateTests(t, []evaluateTest{
+		{"0!", "1", nil},
+		{"1!", "1", nil},
+		{"3!", "6", nil},
+		{"3!!", "720", nil},
+		{"20!", "2432902008176640000", nil},
+		{"2^3!", "64", nil},
+		{"(4/2)!", "2", nil},
+		{"21!", "", ErrInvalidFactorial},
+		{"(1/2)!", "", ErrInvalidFactorial},
+		{"2.5!", "", ErrInvalidFactorial},
+		{"(0-1)!", "", ErrInvalidFactorial},
+		{"(-3)!", "", ErrInvalidFactorial},
+	})
+}
+
+func TestSquareRoot(t *testing.T) {
+	runEvaluateTests(t, []evaluateTest{
+		{"√0", "0", nil},
+		{"√16", "4", nil},
+		{"√(1/4)", "1/2", nil},
+		{"√2.25", "3/2", nil},
+		{"√√16", "2", nil},
+		{"√4^3", "8", nil},
+		{"-√4", "-2", nil},
+		{"√(4!+1)", "5", nil},
+		{"√2", "", ErrInexactRoot},
+		{"√4!", "", ErrInexactRoot},
+		{"√(2/3)", "", ErrInexactRoot},
+		{"√(0-4)", "", ErrNegativeRoot},
+		{"√-4", "", ErrNegativeRoot},
+		{"√--4", "2", nil},
+		{"√-4!", "", ErrNegativeRoot},
+		{"√+4", "2", nil},
+		{"√-0", "0", nil},
+	})
+}
+
+func TestVerifyNeedsExactlyTheTarget(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"(1/3)*300", true},
+		{"1/3*3*100", true},
+		{"100+1/1000000000", false},
+		{"100-1/1000000000", false},
+		{"99.9999999999", false},
+	}
+
+	spec := DefaultSpec
+	spec.Rules = CasualRules
+
+	for _, tt := range tests {
+		got, err := spec.Verify(tt.expr)
+
+		if err != nil {
+			t.Fatalf("Verify(%q): %v", tt.expr, err)
+		}
+
+		if got != tt.want {
+			t.Errorf("Verify(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
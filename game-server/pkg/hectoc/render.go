@@ -0,0 +1,191 @@
+package hectoc
+
+import (
+	"errors"
+	"strings"
+)
+
+// Formats an expression can be rendered in
+const (
+	FORMAT_TEXT   = "text"
+	FORMAT_PRETTY = "pretty"
+	FORMAT_LATEX  = "latex"
+	FORMAT_MATHML = "mathml"
+)
+
+// Formats lists every format Render accepts
+var Formats = []string{FORMAT_TEXT, FORMAT_PRETTY, FORMAT_LATEX, FORMAT_MATHML}
+
+var ErrUnknownFormat = errors.New("unknown format")
+
+// prettyOperators are the typographic forms of the operators
+var prettyOperators = map[string]string{
+	ADD:      "+",
+	SUBTRACT: "−",
+	MULTIPLY: "×",
+	DIVIDE:   "÷",
+	POWER:    "^",
+}
+
+// Render parses an expression and writes it out in the given format
+func Render(expression, format string) (string, error) {
+	e, err := Parse(expression)
+	if err != nil {
+		return "", err
+	}
+
+	switch format {
+	case FORMAT_TEXT:
+		return e.String(), nil
+	case FORMAT_PRETTY:
+		return e.Pretty(), nil
+	case FORMAT_LATEX:
+		return e.LaTeX(), nil
+	case FORMAT_MATHML:
+		return e.MathML(), nil
+	}
+
+	return "", ErrUnknownFormat
+}
+
+// Pretty renders the expression for reading, with typographic operators,
+// spaces around sums and products and only the parentheses it needs
+func (e *Expr) Pretty() string {
+	xParens, yParens := e.operandParens()
+
+	switch e.Kind {
+	case UNARY_EXPR:
+		x := group(e.X.Pretty(), "(", ")", xParens)
+
+		switch e.Op {
+		case FACTORIAL:
+			return x + e.Op
+		case SUBTRACT:
+			return prettyOperators[SUBTRACT] + x
+		}
+		return e.Op + x
+
+	case BINARY_EXPR:
+		x := group(e.X.Pretty(), "(", ")", xParens)
+		y := group(e.Y.Pretty(), "(", ")", yParens)
+
+		if e.Op == POWER {
+			return x + e.Op + y
+		}
+		return x + " " + prettyOperators[e.Op] + " " + y
+	}
+
+	return e.Value
+}
+
+// LaTeX renders the expression as LaTeX math, writing divisions as
+// fractions
+func (e *Expr) LaTeX() string {
+	xParens, yParens := e.operandParens()
+
+	switch e.Kind {
+	case UNARY_EXPR:
+		switch e.Op {
+		case SQUARE_ROOT:
+			return `\sqrt{` + e.X.LaTeX() + `}`
+		case FACTORIAL:
+			return group(e.X.LaTeX(), `\left(`, `\right)`, xParens) + e.Op
+		}
+		return e.Op + group(e.X.LaTeX(), `\left(`, `\right)`, xParens)
+
+	case BINARY_EXPR:
+		switch e.Op {
+		case DIVIDE:
+			return `\frac{` + e.X.LaTeX() + `}{` + e.Y.LaTeX() + `}`
+		case POWER:
+			return `{` + group(e.X.LaTeX(), `\left(`, `\right)`, xParens) + `}^{` + e.Y.LaTeX() + `}`
+		}
+
+		op := ` ` + e.Op + ` `
+		if e.Op == MULTIPLY {
+			op = ` \times `
+		}
+
+		return group(e.X.LaTeX(), `\left(`, `\right)`, xParens) + op + group(e.Y.LaTeX(), `\left(`, `\right)`, yParens)
+	}
+
+	return e.Value
+}
+
+// MathML renders the expression as a presentation MathML element
+func (e *Expr) MathML() string {
+	var sb strings.Builder
+
+	sb.WriteString(`<math xmlns="http://www.w3.org/1998/Math/MathML">`)
+	e.writeMathML(&sb)
+	sb.WriteString(`</math>`)
+
+	return sb.String()
+}
+
+func (e *Expr) writeMathML(sb *strings.Builder) {
+	xParens, yParens := e.operandParens()
+
+	switch e.Kind {
+	case NUMBER_EXPR:
+		sb.WriteString(`<mn>` + e.Value + `</mn>`)
+
+	case UNARY_EXPR:
+		switch e.Op {
+		case SQUARE_ROOT:
+			sb.WriteString(`<msqrt>`)
+			e.X.writeMathML(sb)
+			sb.WriteString(`</msqrt>`)
+		case FACTORIAL:
+			sb.WriteString(`<mrow>`)
+			writeMathMLOperand(sb, e.X, xParens)
+			sb.WriteString(`<mo>!</mo></mrow>`)
+		default:
+			sb.WriteString(`<mrow><mo>` + prettyOperators[e.Op] + `</mo>`)
+			writeMathMLOperand(sb, e.X, xParens)
+			sb.WriteString(`</mrow>`)
+		}
+
+	case BINARY_EXPR:
+		switch e.Op {
+		case DIVIDE:
+			sb.WriteString(`<mfrac><mrow>`)
+			e.X.writeMathML(sb)
+			sb.WriteString(`</mrow><mrow>`)
+			e.Y.writeMathML(sb)
+			sb.WriteString(`</mrow></mfrac>`)
+		case POWER:
+			sb.WriteString(`<msup><mrow>`)
+			writeMathMLOperand(sb, e.X, xParens)
+			sb.WriteString(`</mrow><mrow>`)
+			e.Y.writeMathML(sb)
+			sb.WriteString(`</mrow></msup>`)
+		default:
+			sb.WriteString(`<mrow>`)
+			writeMathMLOperand(sb, e.X, xParens)
+			sb.WriteString(`<mo>` + prettyOperators[e.Op] + `</mo>`)
+			writeMathMLOperand(sb, e.Y, yParens)
+			sb.WriteString(`</mrow>`)
+		}
+	}
+}
+
+func writeMathMLOperand(sb *strings.Builder, e *Expr, parens bool) {
+	if !parens {
+		e.writeMathML(sb)
+		return
+	}
+
+	sb.WriteString(`<mrow><mo>(</mo>`)
+	e.writeMathML(sb)
+	sb.WriteString(`<mo>)</mo></mrow>`)
+}
+
+// group wraps s in the given parentheses if asked to
+func group(s, open, close string, parens bool) string {
+	if parens {
+		return open + s + close
+	}
+
+	return s
+}
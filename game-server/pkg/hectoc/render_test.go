@@ -0,0 +1,227 @@
+package hectoc
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var renderExpressions = []string{
+	"1+2*3",
+	"(1+2)*3",
+	"1-(2-3)",
+	"1*2/3",
+	"1*(2/3)",
+	"(1+2)/(3+4)",
+	"2^3^2",
+	"(2^3)^2",
+	"2^(1/2)",
+	"2^(-1)",
+	"-2^2",
+	"(-2)^2",
+	"-(1+2)",
+	"1*-2",
+	"((1))+2",
+	"3!",
+	"(1+2)!",
+	"√(3+6)*4!",
+	"√√16",
+	"√-4",
+	"1+(2+3+4)*(5+6)",
+}
+
+// prettyToText undoes the typography of the pretty renderer
+var prettyToText = strings.NewReplacer("−", "-", "×", "*", "÷", "/", " ", "")
+
+// latexToText reads back what the LaTeX renderer writes, putting every
+// fraction, power and root in parentheses
+func latexToText(t *testing.T, s string) string {
+	t.Helper()
+
+	var sb strings.Builder
+
+	// group returns the contents of the braces at the start of s and what
+	// follows them
+	group := func(s string) (string, string) {
+		if !strings.HasPrefix(s, "{") {
+			t.Fatalf("expected a group at %q", s)
+		}
+
+		depth := 0
+		for i, r := range s {
+			switch r {
+			case '{':
+				depth++
+			case '}':
+				depth--
+				if depth == 0 {
+					return s[1:i], s[i+1:]
+				}
+			}
+		}
+
+		t.Fatalf("unclosed group in %q", s)
+		return "", ""
+	}
+
+	for s != "" {
+		var a, b string
+
+		switch {
+		case strings.HasPrefix(s, `\frac`):
+			a, s = group(s[len(`\frac`):])
+			b, s = group(s)
+			sb.WriteString("((" + latexToText(t, a) + ")/(" + latexToText(t, b) + "))")
+		case strings.HasPrefix(s, `\sqrt`):
+			a, s = group(s[len(`\sqrt`):])
+			sb.WriteString("√(" + latexToText(t, a) + ")")
+		case strings.HasPrefix(s, "{"):
+			a, s = group(s)
+			if !strings.HasPrefix(s, "^") {
+				t.Fatalf("expected a power at %q", s)
+			}
+			b, s = group(s[1:])
+			sb.WriteString("((" + latexToText(t, a) + ")^(" + latexToText(t, b) + "))")
+		case strings.HasPrefix(s, `\left(`):
+			sb.WriteString("(")
+			s = s[len(`\left(`):]
+		case strings.HasPrefix(s, `\right)`):
+			sb.WriteString(")")
+			s = s[len(`\right)`):]
+		case strings.HasPrefix(s, `\times`):
+			sb.WriteString("*")
+			s = s[len(`\times`):]
+		case s[0] == ' ':
+			s = s[1:]
+		default:
+			sb.WriteByte(s[0])
+			s = s[1:]
+		}
+	}
+
+	return sb.String()
+}
+
+// mathMLToText reads back what the MathML renderer writes, putting every
+// row, fraction, power and root in parentheses
+func mathMLToText(t *testing.T, s string) string {
+	t.Helper()
+
+	d := xml.NewDecoder(strings.NewReader(s))
+
+	var element func(name string) string
+	element = func(name string) string {
+		var children []string
+		var text strings.Builder
+
+		for {
+			token, err := d.Token()
+			if err != nil {
+				t.Fatalf("reading %q: %v", s, err)
+			}
+
+			switch token := token.(type) {
+			case xml.StartElement:
+				children = append(children, element(token.Name.Local))
+			case xml.CharData:
+				text.Write(token)
+			case xml.EndElement:
+				switch name {
+				case "mn":
+					return text.String()
+				case "mo":
+					return prettyToText.Replace(text.String())
+				case "mfrac":
+					return "((" + children[0] + ")/(" + children[1] + "))"
+				case "msup":
+					return "((" + children[0] + ")^(" + children[1] + "))"
+				case "msqrt":
+					return "√(" + strings.Join(children, "") + ")"
+				}
+				return "(" + strings.Join(children, "") + ")"
+			}
+		}
+	}
+
+	token, err := d.Token()
+	if err != nil {
+		t.Fatalf("reading %q: %v", s, err)
+	}
+
+	start, ok := token.(xml.StartElement)
+	if !ok || start.Name.Local != "math" || start.Name.Space != "http://www.w3.org/1998/Math/MathML" {
+		t.Fatalf("%q is not a MathML math element", s)
+	}
+
+	return element("math")
+}
+
+func TestRenderRoundTrips(t *testing.T) {
+	for _, expr := range renderExpressions {
+		t.Run(expr, func(t *testing.T) {
+			e, err := Parse(expr)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			want := e.String()
+
+			readers := map[string]func(string) string{
+				FORMAT_TEXT:   func(s string) string { return s },
+				FORMAT_PRETTY: prettyToText.Replace,
+				FORMAT_LATEX:  func(s string) string { return latexToText(t, s) },
+				FORMAT_MATHML: func(s string) string { return mathMLToText(t, s) },
+			}
+
+			for _, format := range Formats {
+				rendered, err := Render(expr, format)
+				if err != nil {
+					t.Fatalf("Render(%s): %v", format, err)
+				}
+
+				back, err := Parse(readers[format](rendered))
+				if err != nil {
+					t.Fatalf("%s rendering %q does not read back: %v", format, rendered, err)
+				}
+
+				if back.String() != want {
+					t.Fatalf("%s rendering %q reads back as %s, want %s", format, rendered, back, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		expr   string
+		format string
+		want   string
+	}{
+		{"((1))+2", FORMAT_TEXT, "1+2"},
+		{"1*-2", FORMAT_TEXT, "1*(-2)"},
+		{"(1+2)*3", FORMAT_PRETTY, "(1 + 2) × 3"},
+		{"-(1-2)/3", FORMAT_PRETTY, "−(1 − 2) ÷ 3"},
+		{"(1+2)/(3+4)", FORMAT_LATEX, `\frac{1 + 2}{3 + 4}`},
+		{"(2^3)^2", FORMAT_LATEX, `{\left({2}^{3}\right)}^{2}`},
+		{"√(3+6)", FORMAT_LATEX, `\sqrt{3 + 6}`},
+		{"1/2", FORMAT_MATHML, `<math xmlns="http://www.w3.org/1998/Math/MathML"><mfrac><mrow><mn>1</mn></mrow><mrow><mn>2</mn></mrow></mfrac></math>`},
+		{"3!", FORMAT_MATHML, `<math xmlns="http://www.w3.org/1998/Math/MathML"><mrow><mn>3</mn><mo>!</mo></mrow></math>`},
+	}
+
+	for _, tt := range tests {
+		got, err := Render(tt.expr, tt.format)
+		if err != nil {
+			t.Fatalf("Render(%q, %s): %v", tt.expr, tt.format, err)
+		}
+
+		if got != tt.want {
+			t.Errorf("Render(%q, %s) = %s, want %s", tt.expr, tt.format, got, tt.want)
+		}
+	}
+
+	if _, err := Render("1+2", "rtf"); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("Render with an unknown format = %v, want ErrUnknownFormat", err)
+	}
+}
@@ -0,0 +1,198 @@
+package hectoc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rules says which constructs a valid answer may use. Every answer must use
+// the puzzle's digits exactly once, in order, with + - * / and parentheses;
+// the zero value allows nothing beyond that.
+type Rules struct {
+	// Concatenation allows adjacent digits to form one number, e.g. 12
+	Concatenation bool `json:"concatenation"`
+	// UnaryMinus allows a sign in front of an operand, e.g. -(1+2). A
+	// redundant unary plus counts as one.
+	UnaryMinus bool `json:"unaryMinus"`
+	// Powers allows the ^ operator
+	Powers bool `json:"powers"`
+	// Decimals allows a decimal point inside a number, e.g. 1.5
+	Decimals bool `json:"decimals"`
+	// Factorial allows the postfix ! on whole numbers up to MAX_FACTORIAL
+	Factorial bool `json:"factorial"`
+	// SquareRoot allows √ where the root is exact
+	SquareRoot bool `json:"squareRoot"`
+}
+
+var (
+	// OfficialRules are the rules of competitive Hectoc
+	OfficialRules = Rules{
+		Concatenation: true,
+		UnaryMinus:    true,
+		Powers:        true,
+		Decimals:      false,
+	}
+
+	// CasualRules accept decimals on top of the official rules
+	CasualRules = Rules{
+		Concatenation: true,
+		UnaryMinus:    true,
+		Powers:        true,
+		Decimals:      true,
+	}
+)
+
+// RuleSets are the rules a room can pick by name
+var RuleSets = map[string]Rules{
+	"official": OfficialRules,
+	"casual":   CasualRules,
+}
+
+// RuleKind names the rule an answer broke
+type RuleKind string
+
+// Rule kinds
+const (
+	RULE_DIGITS        RuleKind = "digits"
+	RULE_CONCATENATION RuleKind = "concatenation"
+	RULE_UNARY_MINUS   RuleKind = "unary_minus"
+	RULE_POWERS        RuleKind = "powers"
+	RULE_DECIMALS      RuleKind = "decimals"
+	RULE_FACTORIAL     RuleKind = "factorial"
+	RULE_SQUARE_ROOT   RuleKind = "square_root"
+)
+
+var ruleErrorMessages = map[RuleKind]string{
+	RULE_DIGITS:        "the puzzle's digits must be used once each, in order",
+	RULE_CONCATENATION: "concatenating digits is not allowed",
+	RULE_UNARY_MINUS:   "a sign in front of an operand is not allowed",
+	RULE_POWERS:        "powers are not allowed",
+	RULE_DECIMALS:      "decimal numbers are not allowed",
+	RULE_FACTORIAL:     "factorials are not allowed",
+	RULE_SQUARE_ROOT:   "square roots are not allowed",
+}
+
+// RuleError reports which rule an answer broke and where. Pos and End are
+// the byte offsets of the offending part of the expression.
+type RuleError struct {
+	Pos  int      `json:"pos"`
+	End  int      `json:"end"`
+	Kind RuleKind `json:"kind"`
+	Near string   `json:"near,omitempty"`
+}
+
+func (e *RuleError) Error() string {
+	if e.Near != "" {
+		return fmt.Sprintf("%s: %q at position %d", ruleErrorMessages[e.Kind], e.Near, e.Pos)
+	}
+
+	return fmt.Sprintf("%s at position %d", ruleErrorMessages[e.Kind], e.Pos)
+}
+
+// Check reports the first place where the expression breaks the rules for
+// the puzzle, as a *RuleError, or nil if it follows them
+func (r Rules) Check(problem string, e *Expr) error {
+	if err := r.check(e); err != nil {
+		return err
+	}
+
+	return CheckDigits(problem, e)
+}
+
+// check enforces the rules on every node, leaving the digits aside
+func (r Rules) check(e *Expr) error {
+	switch e.Kind {
+	case NUMBER_EXPR:
+		if !r.Decimals && strings.Contains(e.Value, ".") {
+			return &RuleError{Pos: e.Pos, End: e.End, Kind: RULE_DECIMALS, Near: e.Value}
+		}
+
+		if !r.Concatenation && len(strings.ReplaceAll(e.Value, ".", "")) > 1 {
+			return &RuleError{Pos: e.Pos, End: e.End, Kind: RULE_CONCATENATION, Near: e.Value}
+		}
+
+		return nil
+
+	case UNARY_EXPR:
+		switch {
+		case e.Op == FACTORIAL && !r.Factorial:
+			return &RuleError{Pos: e.End - len(e.Op), End: e.End, Kind: RULE_FACTORIAL, Near: e.Op}
+		case e.Op == SQUARE_ROOT && !r.SquareRoot:
+			return &RuleError{Pos: e.Pos, End: e.Pos + len(e.Op), Kind: RULE_SQUARE_ROOT, Near: e.Op}
+		case (e.Op == SUBTRACT || e.Op == ADD) && !r.UnaryMinus:
+			return &RuleError{Pos: e.Pos, End: e.Pos + len(e.Op), Kind: RULE_UNARY_MINUS, Near: e.Op}
+		}
+
+		return r.check(e.X)
+	}
+
+	if !r.Powers && e.Op == POWER {
+		return &RuleError{Pos: e.Pos, End: e.End, Kind: RULE_POWERS}
+	}
+
+	if err := r.check(e.X); err != nil {
+		return err
+	}
+
+	return r.check(e.Y)
+}
+
+// CheckDigits makes sure the numbers of the expression spell out the
+// problem, pointing at the first digit that does not with a *RuleError
+func CheckDigits(problem string, e *Expr) error {
+	next := 0
+	var err error
+
+	var walk func(e *Expr)
+	walk = func(e *Expr) {
+		if err != nil {
+			return
+		}
+
+		switch e.Kind {
+		case NUMBER_EXPR:
+			for i := 0; i < len(e.Value); i++ {
+				if e.Value[i] == '.' {
+					continue
+				}
+
+				if next >= len(problem) || e.Value[i] != problem[next] {
+					err = &RuleError{Pos: e.Pos + i, End: e.Pos + i + 1, Kind: RULE_DIGITS, Near: e.Value[i : i+1]}
+					return
+				}
+
+				next++
+			}
+		case UNARY_EXPR:
+			walk(e.X)
+		case BINARY_EXPR:
+			walk(e.X)
+			walk(e.Y)
+		}
+	}
+
+	walk(e)
+
+	if err == nil && next < len(problem) {
+		err = &RuleError{Pos: e.End, End: e.End, Kind: RULE_DIGITS}
+	}
+
+	return err
+}
+
+// grammar restricts the solver to the expressions the rules allow. The
+// solver never builds decimals, factorials or roots, so those rules do not
+// affect it.
+func (r Rules) grammar() grammar {
+	operators := []string{ADD, SUBTRACT, MULTIPLY, DIVIDE}
+
+	if r.Powers {
+		operators = append(operators, POWER)
+	}
+
+	return grammar{
+		operators:     operators,
+		concatenation: r.Concatenation,
+		negation:      r.UnaryMinus,
+	}
+}
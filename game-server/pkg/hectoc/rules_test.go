@@ -0,0 +1,116 @@
+package hectoc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRulesCheck(t *testing.T) {
+	noConcatenation := OfficialRules
+	noConcatenation.Concatenation = false
+
+	noUnaryMinus := OfficialRules
+	noUnaryMinus.UnaryMinus = false
+
+	noPowers := OfficialRules
+	noPowers.Powers = false
+
+	extended := OfficialRules
+	extended.Factorial = true
+	extended.SquareRoot = true
+
+	tests := []struct {
+		name  string
+		rules Rules
+		expr  string
+		kind  RuleKind
+		pos   int
+	}{
+		{"in order", OfficialRules, "1+2+3+4+5+6", "", 0},
+		{"reordered first", OfficialRules, "2+1+3+4+5+6", RULE_DIGITS, 0},
+		{"reordered last", OfficialRules, "1+2+3+4+6+5", RULE_DIGITS, 8},
+		{"reordered inside a number", OfficialRules, "1+2+3+4+65", RULE_DIGITS, 8},
+		{"missing last", OfficialRules, "1+2+3+4+5", RULE_DIGITS, 9},
+		{"missing middle", OfficialRules, "12+4+56", RULE_DIGITS, 3},
+		{"repeated", OfficialRules, "1+2+3+4+5+5+6", RULE_DIGITS, 10},
+		{"extra", OfficialRules, "1+2+3+4+5+6+7", RULE_DIGITS, 12},
+		{"decimal off", OfficialRules, "1.2+3+4+5+6", RULE_DECIMALS, 0},
+		{"decimal on", CasualRules, "1.2+3+4+5+6", "", 0},
+		{"decimal on, digits checked", CasualRules, "1.3+2+4+5+6", RULE_DIGITS, 2},
+		{"unary minus on", OfficialRules, "-1+2+3+4+5+6", "", 0},
+		{"unary plus on", OfficialRules, "+1+2+3+4+5+6", "", 0},
+		{"unary minus off", noUnaryMinus, "-1+2+3+4+5+6", RULE_UNARY_MINUS, 0},
+		{"unary plus off", noUnaryMinus, "+1+2+3+4+5+6", RULE_UNARY_MINUS, 0},
+		{"unary minus off, nested", noUnaryMinus, "1*-2+3+4+5+6", RULE_UNARY_MINUS, 2},
+		{"binary minus with unary minus off", noUnaryMinus, "1-2+3+4+5+6", "", 0},
+		{"powers on", OfficialRules, "1+2^3+4+5+6", "", 0},
+		{"powers off", noPowers, "1+2^3+4+5+6", RULE_POWERS, 2},
+		{"powers off, whole expression", noPowers, "(1+2)^3+4+5+6", RULE_POWERS, 1},
+		{"concatenation on", OfficialRules, "1+2+34+5+6", "", 0},
+		{"concatenation off", noConcatenation, "1+2+34+5+6", RULE_CONCATENATION, 4},
+		{"concatenation off, first", noConcatenation, "12+3+4+5+6", RULE_CONCATENATION, 0},
+		{"factorial on", extended, "1+2+3!+4+5+6", "", 0},
+		{"factorial off", OfficialRules, "1+2+3!+4+5+6", RULE_FACTORIAL, 5},
+		{"square root on", extended, "√1+2+3+4+5+6", "", 0},
+		{"square root off", OfficialRules, "1+2+√(3+4)+5+6", RULE_SQUARE_ROOT, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			err = tt.rules.Check("123456", e)
+
+			if tt.kind == "" {
+				if err != nil {
+					t.Fatalf("Check(%q) = %v, want nil", tt.expr, err)
+				}
+				return
+			}
+
+			var re *RuleError
+			if !errors.As(err, &re) {
+				t.Fatalf("Check(%q) = %v, want a *RuleError", tt.expr, err)
+			}
+
+			if re.Kind != tt.kind || re.Pos != tt.pos {
+				t.Fatalf("Check(%q) = %s at %d, want %s at %d", tt.expr, re.Kind, re.Pos, tt.kind, tt.pos)
+			}
+		})
+	}
+}
+
+func TestHectocVerifyEnforcesTheRules(t *testing.T) {
+	h, err := DefaultSpec.Puzzle("123456")
+	if err != nil {
+		t.Fatalf("Puzzle: %v", err)
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+		kind RuleKind
+	}{
+		{"1+(2+3+4)*(5+6)", true, ""},
+		{"1+2+3+4+5+6", false, ""},
+		{"(2+1+3+4)*(5+6)+1", false, RULE_DIGITS},
+		{"12*3-4+56+12", false, RULE_DIGITS},
+		{"1.2*3+4+5+6", false, RULE_DECIMALS},
+	}
+
+	for _, tt := range tests {
+		got, err := h.Verify(tt.expr)
+
+		var re *RuleError
+		if tt.kind != "" && (!errors.As(err, &re) || re.Kind != tt.kind) {
+			t.Fatalf("Verify(%q) = %v, %v; want a %s error", tt.expr, got, err, tt.kind)
+		}
+
+		if tt.kind == "" && (err != nil || got != tt.want) {
+			t.Fatalf("Verify(%q) = %v, %v; want %v", tt.expr, got, err, tt.want)
+		}
+	}
+}
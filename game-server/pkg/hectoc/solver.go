@@ -0,0 +1,716 @@
+package hectoc
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// MAX_SOLUTIONS caps how many solutions are produced for one puzzle. The cap
+// counts solutions that are not equivalent to one another, so it never
+// hides a solution behind restatements of another. Solvability is decided
+// exactly regardless of this cap.
+const MAX_SOLUTIONS = 1000
+
+// Bounds for the solver's search. Intermediate values whose numerator or
+// denominator exceed solverMaxValue are dropped, and powers are only taken
+// with integer exponents up to solverMaxExponent in magnitude.
+const (
+	solverMaxValue    = 1 << 31
+	solverMaxExponent = 16
+)
+
+// rat is a small exact rational used by the solver. It is always kept in
+// lowest terms with a positive denominator, so it can be used as a map key.
+type rat struct {
+	num int64
+	den int64
+}
+
+func gcd(a, b int64) int64 {
+	a = abs(a)
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// makeRat normalises num/den, reporting false if it is undefined or out of bounds
+func makeRat(num, den int64) (rat, bool) {
+	if den == 0 {
+		return rat{}, false
+	}
+
+	if den < 0 {
+		num, den = -num, -den
+	}
+
+	if g := gcd(num, den); g > 1 {
+		num, den = num/g, den/g
+	}
+
+	if num > solverMaxValue || num < -solverMaxValue || den > solverMaxValue {
+		return rat{}, false
+	}
+
+	return rat{num, den}, true
+}
+
+func (r rat) isInt() bool {
+	return r.den == 1
+}
+
+func (r rat) neg() rat {
+	return rat{-r.num, r.den}
+}
+
+func (r rat) less(o rat) bool {
+	return r.num*o.den < o.num*r.den
+}
+
+func (r rat) pow(e int64) (rat, bool) {
+	if e < -solverMaxExponent || e > solverMaxExponent {
+		return rat{}, false
+	}
+
+	base := r
+	if e < 0 {
+		if r.num == 0 {
+			return rat{}, false
+		}
+		base, _ = makeRat(r.den, r.num)
+		e = -e
+	}
+
+	result := rat{1, 1}
+	for ; e > 0; e-- {
+		var ok bool
+		if result, ok = apply(MULTIPLY, result, base); !ok {
+			return rat{}, false
+		}
+	}
+
+	return result, true
+}
+
+// checked does int64 arithmetic, remembering whether any step overflowed
+type checked struct {
+	overflow bool
+}
+
+func (c *checked) add(a, b int64) int64 {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		c.overflow = true
+	}
+	return sum
+}
+
+func (c *checked) mul(a, b int64) int64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+
+	product := a * b
+	if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		c.overflow = true
+	}
+	return product
+}
+
+// apply evaluates a op b, reporting false if the result is undefined or out
+// of bounds. The cross products are checked before makeRat reduces them, so
+// an overflow is reported as out of bounds rather than wrapping around.
+func apply(op string, a, b rat) (rat, bool) {
+	var c checked
+	var num, den int64
+
+	switch op {
+	case ADD:
+		num, den = c.add(c.mul(a.num, b.den), c.mul(b.num, a.den)), c.mul(a.den, b.den)
+	case SUBTRACT:
+		num, den = c.add(c.mul(a.num, b.den), -c.mul(b.num, a.den)), c.mul(a.den, b.den)
+	case MULTIPLY:
+		num, den = c.mul(a.num, b.num), c.mul(a.den, b.den)
+	case DIVIDE:
+		num, den = c.mul(a.num, b.den), c.mul(a.den, b.num)
+	case POWER:
+		if !b.isInt() {
+			return rat{}, false
+		}
+		return a.pow(b.num)
+	default:
+		return rat{}, false
+	}
+
+	if c.overflow {
+		return rat{}, false
+	}
+
+	return makeRat(num, den)
+}
+
+// grammar restricts which expression trees the solver builds
+type grammar struct {
+	operators     []string
+	concatenation bool
+	negation      bool
+}
+
+// term is a sub-expression built by the solver, together with the number of
+// unary minuses it contains, so simpler renderings can come first.
+//
+// core is the same expression with its signs moved outwards as far as they
+// go, and negative is the sign left over in front of it: (-2)*3 and -(2*3)
+// share the core 2*3, and (-2)-3 is -(2+3). Terms that share a core and a
+// sign only restate each other.
+type term struct {
+	expr     *Expr
+	negs     int
+	core     *Expr
+	negative bool
+}
+
+func leafTerm(digits string) term {
+	e := &Expr{Kind: NUMBER_EXPR, Value: digits}
+	return term{e, 0, e, false}
+}
+
+// restates returns the key under which terms that restate each other meet
+func (t term) restates() string {
+	if t.negative {
+		return SUBTRACT + "(" + t.core.String() + ")"
+	}
+	return t.core.String()
+}
+
+// signed is the core with the sign in front of it put back
+func (t term) signed() *Expr {
+	if t.negative {
+		return negation(t.core)
+	}
+	return t.core
+}
+
+func negation(e *Expr) *Expr {
+	return &Expr{Kind: UNARY_EXPR, Op: SUBTRACT, X: e}
+}
+
+func binary(op string, x, y *Expr) *Expr {
+	return &Expr{Kind: BINARY_EXPR, Op: op, X: x, Y: y}
+}
+
+type span struct {
+	i, j int
+	v    rat
+}
+
+// solver enumerates every binary expression tree over the ordered digits of
+// a puzzle. Leaves are runs of concatenated digits, inner nodes are binary
+// operators, and unary minus may wrap any sub-expression if the grammar
+// allows it.
+type solver struct {
+	digits  string
+	limit   int
+	grammar grammar
+
+	// values[i][j] holds every value reachable from digits[i:j]. The flag
+	// is true if the value is reachable without a top-level unary minus.
+	// The full interval is never materialised; it is probed on demand.
+	values [][]map[rat]bool
+	sorted [][][]rat
+	memo   map[span][]term
+}
+
+func newSolver(digits string, limit int, g grammar) *solver {
+	n := len(digits)
+
+	s := &solver{
+		digits:  digits,
+		limit:   limit,
+		grammar: g,
+		values:  make([][]map[rat]bool, n+1),
+		sorted:  make([][][]rat, n+1),
+		memo:    make(map[span][]term),
+	}
+
+	for i := range s.values {
+		s.values[i] = make([]map[rat]bool, n+1)
+		s.sorted[i] = make([][]rat, n+1)
+	}
+
+	for length := 1; length < n; length++ {
+		for i := 0; i+length <= n; i++ {
+			s.fill(i, i+length)
+		}
+	}
+
+	return s
+}
+
+// fill computes the value set of digits[i:j] from its sub-intervals
+func (s *solver) fill(i, j int) {
+	direct := make(map[rat]bool)
+
+	if leaf, ok := s.leaf(i, j); ok {
+		direct[leaf] = true
+	}
+
+	for k := i + 1; k < j; k++ {
+		for a := range s.values[i][k] {
+			for b := range s.values[k][j] {
+				for _, op := range s.grammar.operators {
+					if v, ok := apply(op, a, b); ok {
+						direct[v] = true
+					}
+				}
+			}
+		}
+	}
+
+	if !s.grammar.negation {
+		s.values[i][j] = direct
+		return
+	}
+
+	values := make(map[rat]bool, 2*len(direct))
+	for v := range direct {
+		values[v] = true
+	}
+	for v := range direct {
+		if _, ok := values[v.neg()]; !ok {
+			values[v.neg()] = false
+		}
+	}
+
+	s.values[i][j] = values
+}
+
+// list returns the values of digits[i:j] in ascending order
+func (s *solver) list(i, j int) []rat {
+	if s.sorted[i][j] == nil {
+		sorted := make([]rat, 0, len(s.values[i][j]))
+		for v := range s.values[i][j] {
+			sorted = append(sorted, v)
+		}
+		sort.Slice(sorted, func(a, b int) bool {
+			return sorted[a].less(sorted[b])
+		})
+		s.sorted[i][j] = sorted
+	}
+
+	return s.sorted[i][j]
+}
+
+// leaf returns the number formed by concatenating digits[i:j]
+func (s *solver) leaf(i, j int) (rat, bool) {
+	if j-i > 1 && !s.grammar.concatenation {
+		return rat{}, false
+	}
+
+	n, err := strconv.ParseInt(s.digits[i:j], 10, 64)
+	if err != nil {
+		return rat{}, false
+	}
+	return makeRat(n, 1)
+}
+
+// lookup reports whether v is reachable from digits[i:j], and whether it is
+// reachable without a top-level unary minus
+func (s *solver) lookup(i, j int, v rat) (found bool, direct bool) {
+	if values := s.values[i][j]; values != nil {
+		direct, found = values[v]
+		return found, direct
+	}
+
+	if s.reachable(i, j, v) {
+		return true, true
+	}
+
+	if !s.grammar.negation {
+		return false, false
+	}
+
+	return s.reachable(i, j, v.neg()), false
+}
+
+// reachable reports whether v can be built from digits[i:j] without a
+// top-level unary minus, by probing the sub-intervals for matching operands
+func (s *solver) reachable(i, j int, v rat) bool {
+	if leaf, ok := s.leaf(i, j); ok && leaf == v {
+		return true
+	}
+
+	for k := i + 1; k < j; k++ {
+		for a := range s.values[i][k] {
+			for _, op := range s.grammar.operators {
+				if s.hasOperand(op, a, v, k, j) {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func (s *solver) solvable(target rat) bool {
+	found, _ := s.lookup(0, len(s.digits), target)
+	return found
+}
+
+// solutions renders up to s.limit distinct expressions evaluating to target
+func (s *solver) solutions(target rat) []string {
+	if !s.solvable(target) {
+		return nil
+	}
+
+	terms := s.exprs(0, len(s.digits), target)
+
+	solutions := make([]string, 0, len(terms))
+	for _, t := range terms {
+		solutions = append(solutions, t.expr.String())
+	}
+
+	return solutions
+}
+
+// exprs returns the expressions over digits[i:j] that evaluate to v,
+// ordered by how many unary minuses they use. Of the expressions that only
+// restate each other, the one with the fewest unary minuses is kept; over
+// the whole puzzle, so is one of each set of equivalent expressions.
+func (s *solver) exprs(i, j int, v rat) []term {
+	key := span{i, j, v}
+	if terms, ok := s.memo[key]; ok {
+		return terms
+	}
+
+	found, direct := s.lookup(i, j, v)
+	if !found {
+		return nil
+	}
+
+	whole := i == 0 && j == len(s.digits)
+
+	var terms []term
+	seen := make(map[string]int)
+
+	add := func(t term) bool {
+		keys := []string{t.restates()}
+		if whole {
+			keys = []string{canonicalize(t.expr).String(), canonicalize(t.signed()).String()}
+		}
+
+		n := len(terms)
+		for _, key := range keys {
+			if m, dup := seen[key]; dup {
+				if t.negs >= terms[m].negs {
+					return len(terms) < s.limit
+				}
+				n = m
+			}
+		}
+
+		if n == len(terms) {
+			terms = append(terms, t)
+		} else {
+			terms[n] = t
+		}
+		for _, key := range keys {
+			seen[key] = n
+		}
+		return len(terms) < s.limit
+	}
+
+	// Unary minus is only introduced where the value cannot be reached
+	// without it, which keeps trivially negated duplicates out of the list.
+	if !direct {
+		for _, t := range s.exprs(i, j, v.neg()) {
+			if !add(negate(t)) {
+				break
+			}
+		}
+
+		s.memo[key] = terms
+		return terms
+	}
+
+	if leaf, ok := s.leaf(i, j); ok && leaf == v {
+		add(leafTerm(s.digits[i:j]))
+	}
+
+	// The first pass only pairs operands that need no unary minus
+	for pass := 0; pass < 2; pass++ {
+		for k := i + 1; k < j && len(terms) < s.limit; k++ {
+			left := s.values[i][k]
+
+			for _, op := range s.grammar.operators {
+				for _, a := range s.list(i, k) {
+					if len(terms) >= s.limit {
+						break
+					}
+
+					for _, b := range s.operands(op, a, v, k, j) {
+						if !s.wanted(pass, op, left[a], s.values[k][j][b]) {
+							continue
+						}
+
+						if !s.combine(op, s.exprs(i, k, a), s.exprs(k, j, b), add) {
+							break
+						}
+					}
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(terms, func(a, b int) bool {
+		return terms[a].negs < terms[b].negs
+	})
+
+	s.memo[key] = terms
+	return terms
+}
+
+// wanted decides whether a pairing of operands is rendered in the given pass.
+// Pairings that only restate another one, such as x+(-y) for x-y or
+// (-x)*(-y) for x*y, are skipped altogether.
+func (s *solver) wanted(pass int, op string, leftDirect, rightDirect bool) bool {
+	if pass == 0 {
+		return leftDirect && rightDirect
+	}
+
+	if leftDirect && rightDirect {
+		return false
+	}
+
+	switch op {
+	case ADD, SUBTRACT:
+		return rightDirect
+	case MULTIPLY, DIVIDE:
+		return leftDirect || rightDirect
+	}
+
+	return true
+}
+
+// hasOperand reports whether some b in digits[k:j] satisfies a op b == v
+func (s *solver) hasOperand(op string, a, v rat, k, j int) bool {
+	right := s.values[k][j]
+
+	switch op {
+	case MULTIPLY:
+		if a.num == 0 {
+			return v.num == 0
+		}
+	case DIVIDE:
+		if v.num == 0 {
+			return a.num == 0 && len(right) > 1
+		}
+	case POWER:
+		return len(s.exponents(a, v, right)) > 0
+	}
+
+	b, ok := inverse(op, a, v)
+	if !ok {
+		return false
+	}
+
+	_, found := right[b]
+	return found
+}
+
+// operands returns the values b in digits[k:j] for which a op b == v
+func (s *solver) operands(op string, a, v rat, k, j int) []rat {
+	right := s.values[k][j]
+
+	switch op {
+	case MULTIPLY:
+		if a.num == 0 {
+			if v.num == 0 {
+				return s.list(k, j)
+			}
+			return nil
+		}
+	case DIVIDE:
+		if v.num == 0 {
+			if a.num != 0 {
+				return nil
+			}
+			var nonZero []rat
+			for _, b := range s.list(k, j) {
+				if b.num != 0 {
+					nonZero = append(nonZero, b)
+				}
+			}
+			return nonZero
+		}
+	case POWER:
+		return s.exponents(a, v, right)
+	}
+
+	b, ok := inverse(op, a, v)
+	if !ok {
+		return nil
+	}
+
+	if _, found := right[b]; !found {
+		return nil
+	}
+
+	return []rat{b}
+}
+
+// inverse solves a op b == v for b, for every operator except POWER
+func inverse(op string, a, v rat) (rat, bool) {
+	switch op {
+	case ADD:
+		return apply(SUBTRACT, v, a)
+	case SUBTRACT:
+		return apply(SUBTRACT, a, v)
+	case MULTIPLY:
+		return apply(DIVIDE, v, a)
+	case DIVIDE:
+		// a/b == v has no solution when a is zero but v is not
+		if b, ok := apply(DIVIDE, a, v); ok && b.num != 0 {
+			return b, true
+		}
+	}
+
+	return rat{}, false
+}
+
+// exponents returns the integer exponents e in right for which a^e == v,
+// in ascending order
+func (s *solver) exponents(a, v rat, right map[rat]bool) []rat {
+	var found []rat
+
+	check := func(e int64) {
+		if _, ok := right[rat{e, 1}]; ok {
+			found = append(found, rat{e, 1})
+		}
+	}
+
+	if a.num == 0 {
+		if v.num == 0 {
+			for e := int64(1); e <= solverMaxExponent; e++ {
+				check(e)
+			}
+		}
+		return found
+	}
+
+	// Negative exponents are positive powers of the reciprocal
+	inv, _ := makeRat(a.den, a.num)
+	negative := powersMatching(inv, v)
+	for n := len(negative) - 1; n >= 0; n-- {
+		check(-negative[n])
+	}
+
+	if v == (rat{1, 1}) {
+		check(0)
+	}
+
+	for _, e := range powersMatching(a, v) {
+		check(e)
+	}
+
+	return found
+}
+
+// powersMatching returns the exponents e in [1, solverMaxExponent] for which
+// base^e == v, in ascending order
+func powersMatching(base, v rat) []int64 {
+	var matches []int64
+
+	growing := abs(base.num) > base.den
+	shrinking := abs(base.num) < base.den
+
+	p := rat{1, 1}
+	for e := int64(1); e <= solverMaxExponent; e++ {
+		var ok bool
+		if p, ok = apply(MULTIPLY, p, base); !ok {
+			break
+		}
+
+		if p == v {
+			matches = append(matches, e)
+		}
+
+		// Once |p| has moved past |v| it only moves further away
+		if (growing && absLess(v, p)) || (shrinking && absLess(p, v)) {
+			break
+		}
+	}
+
+	return matches
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// absLess reports whether |x| < |y|
+func absLess(x, y rat) bool {
+	return abs(x.num)*y.den < abs(y.num)*x.den
+}
+
+// combine builds every pairing of lefts and rights under op, stopping early
+// once add reports that the limit has been reached
+func (s *solver) combine(op string, lefts, rights []term, add func(term) bool) bool {
+	for _, l := range lefts {
+		for _, r := range rights {
+			if !add(pair(op, l, r)) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// pair joins two terms under op, moving their signs outwards in the core
+func pair(op string, l, r term) term {
+	t := term{
+		expr: binary(op, l.expr, r.expr),
+		negs: l.negs + r.negs,
+	}
+
+	switch op {
+	case ADD, SUBTRACT:
+		// -x+y is -(x-y) and -x-y is -(x+y)
+		subtract := r.negative != (op == SUBTRACT)
+		if subtract != l.negative {
+			t.core = binary(SUBTRACT, l.core, r.core)
+		} else {
+			t.core = binary(ADD, l.core, r.core)
+		}
+		t.negative = l.negative
+
+	case MULTIPLY, DIVIDE:
+		t.core = binary(op, l.core, r.core)
+		t.negative = l.negative != r.negative
+
+	default:
+		t.core = binary(op, l.signed(), r.signed())
+	}
+
+	return t
+}
+
+func negate(t term) term {
+	return term{negation(t.expr), t.negs + 1, t.core, !t.negative}
+}
+
+// Solve returns the classic Hectoc solutions for a digit sequence
+func Solve(problem string) []string {
+	return DefaultSpec.Solve(problem)
+}
+
+// IsSolvable reports whether any expression over the digit sequence reaches 100
+func IsSolvable(problem string) bool {
+	return DefaultSpec.IsSolvable(problem)
+}
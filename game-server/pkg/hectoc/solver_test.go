@@ -0,0 +1,184 @@
+package hectoc
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// bruteForce returns every value some expression over the digits reaches,
+// keyed by RatString. It builds every tree the rules allow without any of
+// the solver's pruning, but drops values outside the solver's bounds.
+func bruteForce(digits string, rules Rules) map[string]*big.Rat {
+	n := len(digits)
+	values := make([][]map[string]*big.Rat, n+1)
+	for i := range values {
+		values[i] = make([]map[string]*big.Rat, n+1)
+	}
+
+	limit := big.NewInt(solverMaxValue)
+	add := func(set map[string]*big.Rat, v *big.Rat) {
+		if new(big.Int).Abs(v.Num()).Cmp(limit) > 0 || v.Denom().Cmp(limit) > 0 {
+			return
+		}
+		set[v.RatString()] = v
+	}
+
+	for length := 1; length <= n; length++ {
+		for i := 0; i+length <= n; i++ {
+			j := i + length
+			set := make(map[string]*big.Rat)
+
+			if length == 1 || rules.Concatenation {
+				v, _ := new(big.Rat).SetString(digits[i:j])
+				add(set, v)
+			}
+
+			for k := i + 1; k < j; k++ {
+				for _, a := range values[i][k] {
+					for _, b := range values[k][j] {
+						add(set, new(big.Rat).Add(a, b))
+						add(set, new(big.Rat).Sub(a, b))
+						add(set, new(big.Rat).Mul(a, b))
+
+						if b.Sign() != 0 {
+							add(set, new(big.Rat).Quo(a, b))
+						}
+
+						if rules.Powers && b.IsInt() && b.Num().IsInt64() {
+							e := b.Num().Int64()
+							if e >= -solverMaxExponent && e <= solverMaxExponent && (e >= 0 || a.Sign() != 0) {
+								if v, err := ratPowInt(a, b.Num()); err == nil {
+									add(set, v)
+								}
+							}
+						}
+					}
+				}
+			}
+
+			if rules.UnaryMinus {
+				for _, v := range set {
+					add(set, new(big.Rat).Neg(v))
+				}
+			}
+
+			values[i][j] = set
+		}
+	}
+
+	return values[0][n]
+}
+
+func TestSolverFindsEveryReachableTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		problem string
+		rules   Rules
+	}{
+		{"arithmetic", "1234", Rules{}},
+		{"arithmetic", "5678", Rules{}},
+		{"powers", "2357", Rules{Powers: true}},
+		{"concatenation", "9999", Rules{Concatenation: true, UnaryMinus: true}},
+		{"official", "1234", OfficialRules},
+		{"official", "2468", OfficialRules},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/"+tt.problem, func(t *testing.T) {
+			reachable := bruteForce(tt.problem, tt.rules)
+
+			for target := int64(-200); target <= 200; target++ {
+				spec := Spec{Digits: len(tt.problem), Target: target, Alphabet: "123456789", Rules: tt.rules}
+				_, want := reachable[big.NewRat(target, 1).RatString()]
+
+				if got := spec.IsSolvable(tt.problem); got != want {
+					t.Fatalf("IsSolvable for %d = %v, brute force says %v", target, got, want)
+				}
+
+				solutions := spec.Solve(tt.problem)
+				if (len(solutions) > 0) != want {
+					t.Fatalf("Solve for %d returned %d solutions, brute force says reachable = %v", target, len(solutions), want)
+				}
+
+				h := &Hectoc{Problem: tt.problem, Spec: spec}
+				for _, solution := range solutions {
+					if ok, err := h.Verify(solution); !ok || err != nil {
+						t.Fatalf("solution %q for %d does not verify: %v", solution, target, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSolveReturnsOnlySolutions(t *testing.T) {
+	for _, problem := range []string{"123456", "999999", "112358", "471298"} {
+		_, want := bruteForce(problem, OfficialRules)["100"]
+		solutions := Solve(problem)
+
+		if IsSolvable(problem) != want || (len(solutions) > 0) != want {
+			t.Fatalf("%s: IsSolvable = %v with %d solutions, brute force says %v", problem, IsSolvable(problem), len(solutions), want)
+		}
+
+		for _, solution := range solutions {
+			digits := strings.Map(func(r rune) rune {
+				if r >= '0' && r <= '9' {
+					return r
+				}
+				return -1
+			}, solution)
+
+			if digits != problem {
+				t.Fatalf("%s: solution %q uses the digits %s", problem, solution, digits)
+			}
+
+			if ok, err := Verify(solution); !ok || err != nil {
+				t.Fatalf("%s: solution %q does not reach 100: %v", problem, solution, err)
+			}
+		}
+	}
+}
+
+func TestSolveSkipsSignRestatements(t *testing.T) {
+	solutions := Solve("123456")
+
+	found := map[string]bool{}
+	for _, solution := range solutions {
+		found[solution] = true
+	}
+
+	for _, want := range []string{"1+(2+3+4)*(5+6)", "(1+2/3)*(4+56)", "-1+(23-4)*5+6"} {
+		if !found[want] {
+			t.Errorf("missing %q in %q", want, solutions)
+		}
+	}
+
+	// Each of these only moves the signs of a solution above
+	for _, restated := range []string{
+		"1-(-2-3-4)*(5+6)",
+		"1+(-2-3-4)*(-5-6)",
+		"1-(2+3+4)*(-5-6)",
+		"(-1-2/3)*(-4-56)",
+		"-1-(-23+4)*5+6",
+	} {
+		if found[restated] {
+			t.Errorf("%q restates another solution", restated)
+		}
+	}
+}
+
+func TestApplyReportsOverflow(t *testing.T) {
+	huge := rat{math.MaxInt64, 1}
+
+	for _, op := range []string{ADD, SUBTRACT, MULTIPLY, DIVIDE} {
+		if v, ok := apply(op, huge, rat{-3, 2}); ok {
+			t.Errorf("%v %s -3/2 = %v, want overflow", huge, op, v)
+		}
+	}
+
+	if v, ok := apply(ADD, rat{solverMaxValue, 1}, rat{1, 1}); ok {
+		t.Errorf("sum out of bounds gave %v", v)
+	}
+}
@@ -0,0 +1,175 @@
+package hectoc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// MAX_DIGITS is the longest digit sequence a puzzle may have. The solver's
+// cost grows steeply with every extra digit.
+const MAX_DIGITS = 8
+
+var (
+	ErrInvalidSpec = errors.New("invalid puzzle spec")
+	ErrNoPuzzle    = errors.New("no solvable puzzle found")
+)
+
+// Spec describes a puzzle variant: how many digits are dealt, which digits
+// may be dealt, the value the expression has to reach and the rules an
+// answer has to follow
+type Spec struct {
+	Digits   int    `json:"digits"`
+	Target   int64  `json:"target"`
+	Alphabet string `json:"alphabet"`
+	Rules    Rules  `json:"rules"`
+}
+
+// DefaultSpec is classic Hectoc: six digits from 1 to 9, reaching 100 under
+// the official rules
+var DefaultSpec = Spec{
+	Digits:   6,
+	Target:   100,
+	Alphabet: "123456789",
+	Rules:    OfficialRules,
+}
+
+// Validate checks that the spec describes a puzzle the package can handle
+func (s Spec) Validate() error {
+	if s.Digits < 1 || s.Digits > MAX_DIGITS {
+		return fmt.Errorf("%w: digits must be between 1 and %d", ErrInvalidSpec, MAX_DIGITS)
+	}
+
+	if s.Target < -solverMaxValue || s.Target > solverMaxValue {
+		return fmt.Errorf("%w: target out of range", ErrInvalidSpec)
+	}
+
+	if s.Alphabet == "" {
+		return fmt.Errorf("%w: alphabet is empty", ErrInvalidSpec)
+	}
+
+	for _, char := range s.Alphabet {
+		if char < '0' || char > '9' {
+			return fmt.Errorf("%w: alphabet may only contain digits", ErrInvalidSpec)
+		}
+	}
+
+	return nil
+}
+
+// Matches reports whether a digit sequence is a valid problem for the spec
+func (s Spec) Matches(problem string) bool {
+	if len(problem) != s.Digits {
+		return false
+	}
+
+	for _, char := range problem {
+		if !strings.ContainsRune(s.Alphabet, char) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Verify reports whether an expression follows the rules and evaluates
+// exactly to the target. The digits are not checked, since the spec does not
+// know the puzzle; Hectoc.Verify does.
+func (s Spec) Verify(expression string) (bool, error) {
+	e, err := Parse(expression)
+
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.Rules.check(e); err != nil {
+		return false, err
+	}
+
+	return s.reaches(e)
+}
+
+// reaches reports whether a parsed expression evaluates exactly to the target
+func (s Spec) reaches(e *Expr) (bool, error) {
+	result, err := e.Evaluate()
+
+	if err != nil {
+		return false, err
+	}
+
+	if result.Cmp(big.NewRat(s.Target, 1)) != 0 {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Solve returns the solutions for a digit sequence, up to MAX_SOLUTIONS of
+// them, keeping only one rendering of each set of equivalent expressions
+func (s Spec) Solve(problem string) []string {
+	return newSolver(problem, MAX_SOLUTIONS, s.Rules.grammar()).solutions(rat{s.Target, 1})
+}
+
+// IsSolvable reports whether any expression over the digit sequence reaches the target
+func (s Spec) IsSolvable(problem string) bool {
+	return newSolver(problem, MAX_SOLUTIONS, s.Rules.grammar()).solvable(rat{s.Target, 1})
+}
+
+// Generate returns a solvable puzzle of any difficulty. Classic puzzles fall
+// back to a known solvable sequence instead of failing.
+func (s Spec) Generate() (*Hectoc, error) {
+	h, err := s.GenerateWithDifficulty(0, MAX_DIFFICULTY)
+
+	if err == nil || s != DefaultSpec {
+		return h, err
+	}
+
+	h = &Hectoc{
+		Problem: fallbacks[rand.Intn(len(fallbacks))],
+		Spec:    s,
+	}
+
+	h.solve()
+
+	return h, nil
+}
+
+// GenerateWithDifficulty returns a solvable puzzle whose difficulty score
+// lies in [minScore, maxScore], using a freshly seeded generator
+func (s Spec) GenerateWithDifficulty(minScore, maxScore int) (*Hectoc, error) {
+	return NewGenerator(s, rand.NewSource(time.Now().UnixNano())).GenerateWithDifficulty(minScore, maxScore)
+}
+
+// Puzzle returns the puzzle for a known digit sequence, solved and rated
+func (s Spec) Puzzle(problem string) (*Hectoc, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	if !s.Matches(problem) {
+		return nil, fmt.Errorf("%w: %q does not fit the spec", ErrInvalidSpec, problem)
+	}
+
+	h := &Hectoc{
+		Problem: problem,
+		Spec:    s,
+	}
+
+	h.solve()
+
+	return h, nil
+}
+
+// deal draws a random digit sequence from the spec's alphabet
+func (s Spec) deal(r *rand.Rand) string {
+	sequence := make([]byte, s.Digits)
+
+	for i := range sequence {
+		sequence[i] = s.Alphabet[r.Intn(len(s.Alphabet))]
+	}
+
+	return string(sequence)
+}
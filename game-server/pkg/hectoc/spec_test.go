@@ -0,0 +1,120 @@
+package hectoc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSpecValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		spec Spec
+		ok   bool
+	}{
+		{"classic", DefaultSpec, true},
+		{"one digit", Spec{Digits: 1, Target: 7, Alphabet: "7"}, true},
+		{"longest", Spec{Digits: MAX_DIGITS, Target: 100, Alphabet: "123456789"}, true},
+		{"with zero", Spec{Digits: 4, Target: 24, Alphabet: "0123456789"}, true},
+		{"negative target", Spec{Digits: 4, Target: -24, Alphabet: "123456789"}, true},
+		{"no digits", Spec{Digits: 0, Target: 100, Alphabet: "123456789"}, false},
+		{"too many digits", Spec{Digits: MAX_DIGITS + 1, Target: 100, Alphabet: "123456789"}, false},
+		{"target too large", Spec{Digits: 6, Target: solverMaxValue + 1, Alphabet: "123456789"}, false},
+		{"target too small", Spec{Digits: 6, Target: -solverMaxValue - 1, Alphabet: "123456789"}, false},
+		{"empty alphabet", Spec{Digits: 6, Target: 100}, false},
+		{"letters in alphabet", Spec{Digits: 6, Target: 100, Alphabet: "12a"}, false},
+	}
+
+	for _, tt := range tests {
+		err := tt.spec.Validate()
+
+		if tt.ok && err != nil {
+			t.Errorf("%s: Validate() = %v, want nil", tt.name, err)
+		}
+
+		if !tt.ok && !errors.Is(err, ErrInvalidSpec) {
+			t.Errorf("%s: Validate() = %v, want ErrInvalidSpec", tt.name, err)
+		}
+	}
+}
+
+func TestSpecMatches(t *testing.T) {
+	small := Spec{Digits: 4, Target: 24, Alphabet: "1234"}
+
+	tests := []struct {
+		spec    Spec
+		problem string
+		want    bool
+	}{
+		{DefaultSpec, "123456", true},
+		{DefaultSpec, "999999", true},
+		{DefaultSpec, "12345", false},
+		{DefaultSpec, "1234567", false},
+		{DefaultSpec, "123450", false},
+		{DefaultSpec, "12345a", false},
+		{DefaultSpec, "", false},
+		{small, "4321", true},
+		{small, "1235", false},
+		{small, "123", false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.spec.Matches(tt.problem); got != tt.want {
+			t.Errorf("%+v.Matches(%q) = %v, want %v", tt.spec, tt.problem, got, tt.want)
+		}
+	}
+}
+
+func TestSpecTarget(t *testing.T) {
+	spec := Spec{Digits: 4, Target: 24, Alphabet: "123456789", Rules: OfficialRules}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"4*6", true},
+		{"(1+2+3)*4", true},
+		{"100", false},
+		{"25-1", true},
+	}
+
+	for _, tt := range tests {
+		got, err := spec.Verify(tt.expr)
+
+		if err != nil {
+			t.Fatalf("Verify(%q): %v", tt.expr, err)
+		}
+
+		if got != tt.want {
+			t.Errorf("Verify(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	if !spec.IsSolvable("1234") || spec.IsSolvable("1111") {
+		t.Fatalf("1234 should reach 24 and 1111 should not")
+	}
+
+	for _, solution := range spec.Solve("1234") {
+		if ok, err := spec.Verify(solution); !ok || err != nil {
+			t.Fatalf("solution %q does not reach 24: %v", solution, err)
+		}
+	}
+}
+
+func TestSpecGenerateFitsTheSpec(t *testing.T) {
+	spec := Spec{Digits: 4, Target: 24, Alphabet: "2468", Rules: OfficialRules}
+
+	for i := 0; i < 5; i++ {
+		h, err := spec.Generate()
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+
+		if !spec.Matches(h.Problem) || len(h.Solutions) == 0 {
+			t.Fatalf("got %+v, want a solvable puzzle of the spec", h)
+		}
+	}
+
+	if _, err := (Spec{Digits: 0, Target: 24, Alphabet: "2468"}).Generate(); !errors.Is(err, ErrInvalidSpec) {
+		t.Fatalf("Generate with an invalid spec = %v, want ErrInvalidSpec", err)
+	}
+}
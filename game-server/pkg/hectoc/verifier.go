@@ -1,19 +1,6 @@
 package hectoc
 
-const answer = 100.0
-
+// Verify reports whether an expression evaluates exactly to 100
 func Verify(expression string) (bool, error) {
-	calculator := newCalculator()
-
-	result, err := calculator.calculate(expression)
-
-	if err != nil {
-		return false, err
-	}
-
-	if result != answer {
-		return false, nil
-	}
-
-	return true, nil
-} 
\ No newline at end of file
+	return DefaultSpec.Verify(expression)
+}
@@ -7,9 +7,9 @@ import (
 // K-factor determines how much ratings change after each game
 const KFactor = 32
 
-// UpdateRatings calculates new ELO ratings after a game between two players
+// GetNewRatings calculates new ELO ratings after a game between two players
 // Parameters:
-// - winnerId: ID of the winning player (if 0, the game was a draw)
+// - winnerId: ID of the winning player (if neither player's, the game was a draw)
 // - player1Id: ID of the first player
 // - player1Rating: current rating of the first player
 // - player2Id: ID of the second player
@@ -20,12 +20,16 @@ const KFactor = 32
 func GetNewRatings(player1Id, player2Id, winnerId int64, player1Rating, player2Rating int) (int, int) {
 	// Determine the outcome of the match
 	var outcome float64
-	if winnerId == player1Id {
+	switch winnerId {
+	case player1Id:
 		// Player 1 wins
 		outcome = 1.0
-	} else {
-		// Player 2 wins (assume it's player2Id)
+	case player2Id:
+		// Player 2 wins
 		outcome = 0.0
+	default:
+		// Draw
+		outcome = 0.5
 	}
 
 	// Calculate expected outcome based on current ratings
@@ -0,0 +1,44 @@
+package rating
+
+import "testing"
+
+func TestGetNewRatings(t *testing.T) {
+	tests := []struct {
+		name             string
+		winnerID         int64
+		rating1, rating2 int
+		want1, want2     int
+	}{
+		{"win between equals", 1, 1200, 1200, 1216, 1184},
+		{"loss between equals", 2, 1200, 1200, 1184, 1216},
+		{"draw between equals", 0, 1200, 1200, 1200, 1200},
+		{"favourite wins", 1, 1400, 1200, 1408, 1192},
+		{"underdog wins", 2, 1400, 1200, 1376, 1224},
+		{"draw with a favourite", 0, 1400, 1200, 1392, 1208},
+		{"draw with an underdog", 0, 1200, 1400, 1208, 1392},
+		{"rating stays at or above zero", 2, 10, 10, 0, 26},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got1, got2 := GetNewRatings(1, 2, tt.winnerID, tt.rating1, tt.rating2)
+
+			if got1 != tt.want1 || got2 != tt.want2 {
+				t.Fatalf("GetNewRatings(%d, %d) with winner %d = %d, %d, want %d, %d",
+					tt.rating1, tt.rating2, tt.winnerID, got1, got2, tt.want1, tt.want2)
+			}
+		})
+	}
+}
+
+// A draw is a draw whichever ID it is reported with, as long as it is
+// neither player's
+func TestDrawIgnoresUnknownWinner(t *testing.T) {
+	for _, winnerID := range []int64{0, -1, 3} {
+		got1, got2 := GetNewRatings(1, 2, winnerID, 1300, 1300)
+
+		if got1 != 1300 || got2 != 1300 {
+			t.Fatalf("winner %d: got %d, %d, want the ratings unchanged", winnerID, got1, got2)
+		}
+	}
+}